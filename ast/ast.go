@@ -2,6 +2,7 @@ package ast
 
 import (
 	"bytes"
+	"fmt"
 	"monkey/token"
 	"strings"
 )
@@ -48,16 +49,25 @@ func (p *Program) String() string {
 
 // Statements
 type LetStatement struct {
-	Token token.Token // the token.LET token
-	Name  *Identifier
-	Value Expression
+	Token    token.Token // the token.LET or token.CONST token
+	Name     *Identifier
+	Value    Expression
+	Exported bool // declared with `export`, visible to importing modules
 }
 
 func (ls *LetStatement) statementNode()       {}
 func (ls *LetStatement) TokenLiteral() string { return ls.Token.Literal }
+
+// IsConst reports whether the binding was declared with `const` and so
+// cannot be assigned to or redeclared in the same scope.
+func (ls *LetStatement) IsConst() bool { return ls.Token.Type == token.CONST }
+
 func (ls *LetStatement) String() string {
 	var out bytes.Buffer
 
+	if ls.Exported {
+		out.WriteString("export ")
+	}
 	out.WriteString(ls.TokenLiteral() + " ")
 	out.WriteString(ls.Name.String())
 	out.WriteString(" = ")
@@ -71,6 +81,80 @@ func (ls *LetStatement) String() string {
 	return out.String()
 }
 
+type ImportStatement struct {
+	Token token.Token // the 'import' token
+	Path  *StringLiteral
+	Alias *Identifier
+}
+
+func (is *ImportStatement) statementNode()       {}
+func (is *ImportStatement) TokenLiteral() string { return is.Token.Literal }
+func (is *ImportStatement) String() string {
+	return fmt.Sprintf("import %q as %s;", is.Path.Value, is.Alias.String())
+}
+
+type ThrowStatement struct {
+	Token token.Token // the 'throw' token
+	Value Expression
+}
+
+func (ts *ThrowStatement) statementNode()       {}
+func (ts *ThrowStatement) TokenLiteral() string { return ts.Token.Literal }
+func (ts *ThrowStatement) String() string {
+	return "throw " + ts.Value.String() + ";"
+}
+
+type TryStatement struct {
+	Token   token.Token // the 'try' token
+	Block   *BlockStatement
+	Param   *Identifier     // may be nil
+	Catch   *BlockStatement // may be nil if Finally is set
+	Finally *BlockStatement // may be nil if Catch is set
+}
+
+func (ts *TryStatement) statementNode()       {}
+func (ts *TryStatement) TokenLiteral() string { return ts.Token.Literal }
+func (ts *TryStatement) String() string {
+	var out bytes.Buffer
+
+	out.WriteString("try {" + ts.Block.String() + "}")
+	if ts.Catch != nil {
+		out.WriteString(" catch ")
+		if ts.Param != nil {
+			out.WriteString("(" + ts.Param.String() + ") ")
+		}
+		out.WriteString("{" + ts.Catch.String() + "}")
+	}
+	if ts.Finally != nil {
+		out.WriteString(" finally {" + ts.Finally.String() + "}")
+	}
+
+	return out.String()
+}
+
+// StatementLine returns the line s starts on, which the engines report as
+// the location of errors raised while running it.
+func StatementLine(s Statement) int {
+	switch s := s.(type) {
+	case *LetStatement:
+		return s.Token.Line
+	case *ReturnStatement:
+		return s.Token.Line
+	case *ExpressionStatement:
+		return s.Token.Line
+	case *BlockStatement:
+		return s.Token.Line
+	case *ImportStatement:
+		return s.Token.Line
+	case *ThrowStatement:
+		return s.Token.Line
+	case *TryStatement:
+		return s.Token.Line
+	default:
+		return 0
+	}
+}
+
 type ReturnStatement struct {
 	Token       token.Token // the 'return' token
 	ReturnValue Expression
@@ -142,6 +226,14 @@ func (b *Boolean) expressionNode()      {}
 func (b *Boolean) TokenLiteral() string { return b.Token.Literal }
 func (b *Boolean) String() string       { return b.Token.Literal }
 
+type NullLiteral struct {
+	Token token.Token
+}
+
+func (nl *NullLiteral) expressionNode()      {}
+func (nl *NullLiteral) TokenLiteral() string { return nl.Token.Literal }
+func (nl *NullLiteral) String() string       { return nl.Token.Literal }
+
 type IntegerLiteral struct {
 	Token token.Token
 	Value int64
@@ -219,7 +311,10 @@ func (ie *IfExpression) String() string {
 type FunctionLiteral struct {
 	Token      token.Token // The 'fn' token
 	Parameters []*Identifier
+	Defaults   map[string]Expression // default values, keyed by parameter name
+	Rest       *Identifier           // collects extra arguments, may be nil
 	Body       *BlockStatement
+	Name       string // set when the literal is bound by a let statement
 }
 
 func (fl *FunctionLiteral) expressionNode()      {}
@@ -227,16 +322,75 @@ func (fl *FunctionLiteral) TokenLiteral() string { return fl.Token.Literal }
 func (fl *FunctionLiteral) String() string {
 	var out bytes.Buffer
 
+	if fl.IsArrow() {
+		out.WriteString("(")
+		out.WriteString(strings.Join(ParameterList(fl.Parameters, fl.Defaults, fl.Rest), ", "))
+		out.WriteString(") => ")
+		out.WriteString(fl.Body.String())
+
+		return out.String()
+	}
+
+	out.WriteString(fl.TokenLiteral())
+	if fl.Name != "" {
+		out.WriteString(fmt.Sprintf("<%s>", fl.Name))
+	}
+	out.WriteString("(")
+	out.WriteString(strings.Join(ParameterList(fl.Parameters, fl.Defaults, fl.Rest), ", "))
+	out.WriteString(") ")
+	out.WriteString(fl.Body.String())
+
+	return out.String()
+}
+
+// IsArrow reports whether the literal was written with the concise
+// `params => body` syntax.
+func (fl *FunctionLiteral) IsArrow() bool { return fl.Token.Type == token.ARROW }
+
+// ParameterList renders a parameter list the way it is written in source,
+// including default values and the rest parameter.
+func ParameterList(
+	params []*Identifier,
+	defaults map[string]Expression,
+	rest *Identifier,
+) []string {
+	out := []string{}
+	for _, p := range params {
+		if def, ok := defaults[p.Value]; ok {
+			out = append(out, p.String()+" = "+def.String())
+		} else {
+			out = append(out, p.String())
+		}
+	}
+
+	if rest != nil {
+		out = append(out, "..."+rest.String())
+	}
+
+	return out
+}
+
+type MacroLiteral struct {
+	Token      token.Token // The 'macro' token
+	Parameters []*Identifier
+	Body       *BlockStatement
+}
+
+func (ml *MacroLiteral) expressionNode()      {}
+func (ml *MacroLiteral) TokenLiteral() string { return ml.Token.Literal }
+func (ml *MacroLiteral) String() string {
+	var out bytes.Buffer
+
 	params := []string{}
-	for _, p := range fl.Parameters {
+	for _, p := range ml.Parameters {
 		params = append(params, p.String())
 	}
 
-	out.WriteString(fl.TokenLiteral())
+	out.WriteString(ml.TokenLiteral())
 	out.WriteString("(")
 	out.WriteString(strings.Join(params, ", "))
 	out.WriteString(") ")
-	out.WriteString(fl.Body.String())
+	out.WriteString(ml.Body.String())
 
 	return out.String()
 }
@@ -265,6 +419,57 @@ func (ce *CallExpression) String() string {
 	return out.String()
 }
 
+type AssignExpression struct {
+	Token token.Token // the '=' token
+	Name  *Identifier
+	Value Expression
+}
+
+func (ae *AssignExpression) expressionNode()      {}
+func (ae *AssignExpression) TokenLiteral() string { return ae.Token.Literal }
+func (ae *AssignExpression) String() string {
+	var out bytes.Buffer
+
+	out.WriteString("(")
+	out.WriteString(ae.Name.String())
+	out.WriteString(" = ")
+	out.WriteString(ae.Value.String())
+	out.WriteString(")")
+
+	return out.String()
+}
+
+type PipeExpression struct {
+	Token token.Token // the '|>' token
+	Left  Expression
+	Right Expression
+}
+
+func (pe *PipeExpression) expressionNode()      {}
+func (pe *PipeExpression) TokenLiteral() string { return pe.Token.Literal }
+func (pe *PipeExpression) String() string {
+	var out bytes.Buffer
+
+	out.WriteString("(")
+	out.WriteString(pe.Left.String())
+	out.WriteString(" |> ")
+	out.WriteString(pe.Right.String())
+	out.WriteString(")")
+
+	return out.String()
+}
+
+// Call returns the call the pipeline stands for: Left becomes the first
+// argument when Right is a call, and the only argument otherwise.
+func (pe *PipeExpression) Call() *CallExpression {
+	if call, ok := pe.Right.(*CallExpression); ok {
+		args := append([]Expression{pe.Left}, call.Arguments...)
+		return &CallExpression{Token: call.Token, Function: call.Function, Arguments: args}
+	}
+
+	return &CallExpression{Token: pe.Token, Function: pe.Right, Arguments: []Expression{pe.Left}}
+}
+
 type StringLiteral struct {
 	Token token.Token
 	Value string
@@ -274,6 +479,32 @@ func (sl *StringLiteral) expressionNode()      {}
 func (sl *StringLiteral) TokenLiteral() string { return sl.Token.Literal }
 func (sl *StringLiteral) String() string       { return sl.Token.Literal }
 
+// InterpolatedString is a string literal with ${...} placeholders. Parts
+// alternates between *StringLiteral text and embedded expressions.
+type InterpolatedString struct {
+	Token token.Token // the token.INTERP_STRING token
+	Parts []Expression
+}
+
+func (is *InterpolatedString) expressionNode()      {}
+func (is *InterpolatedString) TokenLiteral() string { return is.Token.Literal }
+
+// String rebuilds the literal from its parts, so that it shows any
+// rewrite of them.
+func (is *InterpolatedString) String() string {
+	var out bytes.Buffer
+
+	for _, part := range is.Parts {
+		if text, ok := part.(*StringLiteral); ok {
+			out.WriteString(text.String())
+			continue
+		}
+		out.WriteString("${" + part.String() + "}")
+	}
+
+	return out.String()
+}
+
 type ArrayLiteral struct {
 	Token    token.Token // the '[' token
 	Elements []Expression
@@ -297,18 +528,31 @@ func (al *ArrayLiteral) String() string {
 }
 
 type IndexExpression struct {
-	Token token.Token // The [ token
-	Left  Expression
-	Index Expression
+	Token    token.Token // The [, ?[ or . token
+	Left     Expression
+	Index    Expression
+	Optional bool // a?[k] and the calls and indexes after it yield null if a is null
 }
 
 func (ie *IndexExpression) expressionNode()      {}
 func (ie *IndexExpression) TokenLiteral() string { return ie.Token.Literal }
+
+// IsMember reports whether the expression was written as `a.name`, which
+// indexes a with the string "name".
+func (ie *IndexExpression) IsMember() bool { return ie.Token.Type == token.DOT }
+
 func (ie *IndexExpression) String() string {
 	var out bytes.Buffer
 
 	out.WriteString("(")
 	out.WriteString(ie.Left.String())
+	if ie.IsMember() {
+		out.WriteString("." + ie.Index.String() + ")")
+		return out.String()
+	}
+	if ie.Optional {
+		out.WriteString("?")
+	}
 	out.WriteString("[")
 	out.WriteString(ie.Index.String())
 	out.WriteString("])")
@@ -318,7 +562,12 @@ func (ie *IndexExpression) String() string {
 
 type HashLiteral struct {
 	Token token.Token // the '{' token
-	Pairs map[Expression]Expression
+	Pairs []HashPair  // in source order
+}
+
+type HashPair struct {
+	Key   Expression
+	Value Expression
 }
 
 func (hl *HashLiteral) expressionNode()      {}
@@ -327,8 +576,8 @@ func (hl *HashLiteral) String() string {
 	var out bytes.Buffer
 
 	pairs := []string{}
-	for key, value := range hl.Pairs {
-		pairs = append(pairs, key.String()+":"+value.String())
+	for _, pair := range hl.Pairs {
+		pairs = append(pairs, pair.Key.String()+":"+pair.Value.String())
 	}
 
 	out.WriteString("{")
@@ -337,3 +586,12 @@ func (hl *HashLiteral) String() string {
 
 	return out.String()
 }
+
+type SpreadExpression struct {
+	Token token.Token // the '...' token
+	Value Expression
+}
+
+func (se *SpreadExpression) expressionNode()      {}
+func (se *SpreadExpression) TokenLiteral() string { return se.Token.Literal }
+func (se *SpreadExpression) String() string       { return "..." + se.Value.String() }
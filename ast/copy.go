@@ -0,0 +1,227 @@
+package ast
+
+import "fmt"
+
+// Copy returns a deep copy of the tree rooted at node, which can be
+// rewritten without changing the original. Tokens are copied by value.
+func Copy(node Node) Node {
+	if node == nil {
+		return nil
+	}
+
+	switch n := node.(type) {
+	case *Program:
+		return &Program{Statements: copyStatements(n.Statements)}
+
+	case *LetStatement:
+		c := *n
+		c.Name = copyIdentifier(n.Name)
+		c.Value = copyExpression(n.Value)
+		return &c
+
+	case *ImportStatement:
+		c := *n
+		if n.Path != nil {
+			c.Path = Copy(n.Path).(*StringLiteral)
+		}
+		c.Alias = copyIdentifier(n.Alias)
+		return &c
+
+	case *ThrowStatement:
+		c := *n
+		c.Value = copyExpression(n.Value)
+		return &c
+
+	case *TryStatement:
+		c := *n
+		c.Block = copyBlock(n.Block)
+		c.Param = copyIdentifier(n.Param)
+		c.Catch = copyBlock(n.Catch)
+		c.Finally = copyBlock(n.Finally)
+		return &c
+
+	case *ReturnStatement:
+		c := *n
+		c.ReturnValue = copyExpression(n.ReturnValue)
+		return &c
+
+	case *ExpressionStatement:
+		c := *n
+		c.Expression = copyExpression(n.Expression)
+		return &c
+
+	case *BlockStatement:
+		c := *n
+		c.Statements = copyStatements(n.Statements)
+		return &c
+
+	case *Identifier:
+		c := *n
+		return &c
+
+	case *Boolean:
+		c := *n
+		return &c
+
+	case *NullLiteral:
+		c := *n
+		return &c
+
+	case *IntegerLiteral:
+		c := *n
+		return &c
+
+	case *StringLiteral:
+		c := *n
+		return &c
+
+	case *PrefixExpression:
+		c := *n
+		c.Right = copyExpression(n.Right)
+		return &c
+
+	case *InfixExpression:
+		c := *n
+		c.Left = copyExpression(n.Left)
+		c.Right = copyExpression(n.Right)
+		return &c
+
+	case *IfExpression:
+		c := *n
+		c.Condition = copyExpression(n.Condition)
+		c.Consequence = copyBlock(n.Consequence)
+		c.Alternative = copyBlock(n.Alternative)
+		return &c
+
+	case *FunctionLiteral:
+		c := *n
+		c.Parameters = copyIdentifiers(n.Parameters)
+		if n.Defaults != nil {
+			c.Defaults = make(map[string]Expression, len(n.Defaults))
+			for name, def := range n.Defaults {
+				c.Defaults[name] = copyExpression(def)
+			}
+		}
+		c.Rest = copyIdentifier(n.Rest)
+		c.Body = copyBlock(n.Body)
+		return &c
+
+	case *MacroLiteral:
+		c := *n
+		c.Parameters = copyIdentifiers(n.Parameters)
+		c.Body = copyBlock(n.Body)
+		return &c
+
+	case *CallExpression:
+		c := *n
+		c.Function = copyExpression(n.Function)
+		c.Arguments = copyExpressions(n.Arguments)
+		return &c
+
+	case *AssignExpression:
+		c := *n
+		c.Name = copyIdentifier(n.Name)
+		c.Value = copyExpression(n.Value)
+		return &c
+
+	case *PipeExpression:
+		c := *n
+		c.Left = copyExpression(n.Left)
+		c.Right = copyExpression(n.Right)
+		return &c
+
+	case *InterpolatedString:
+		c := *n
+		c.Parts = copyExpressions(n.Parts)
+		return &c
+
+	case *ArrayLiteral:
+		c := *n
+		c.Elements = copyExpressions(n.Elements)
+		return &c
+
+	case *IndexExpression:
+		c := *n
+		c.Left = copyExpression(n.Left)
+		c.Index = copyExpression(n.Index)
+		return &c
+
+	case *HashLiteral:
+		c := *n
+		if n.Pairs != nil {
+			c.Pairs = make([]HashPair, len(n.Pairs))
+			for i, pair := range n.Pairs {
+				c.Pairs[i] = HashPair{
+					Key:   copyExpression(pair.Key),
+					Value: copyExpression(pair.Value),
+				}
+			}
+		}
+		return &c
+
+	case *SpreadExpression:
+		c := *n
+		c.Value = copyExpression(n.Value)
+		return &c
+
+	default:
+		panic(fmt.Sprintf("ast.Copy: unexpected node type %T", n))
+	}
+}
+
+func copyStatements(list []Statement) []Statement {
+	if list == nil {
+		return nil
+	}
+	copied := make([]Statement, len(list))
+	for i, s := range list {
+		if s != nil {
+			copied[i] = Copy(s).(Statement)
+		}
+	}
+	return copied
+}
+
+func copyExpressions(list []Expression) []Expression {
+	if list == nil {
+		return nil
+	}
+	copied := make([]Expression, len(list))
+	for i, e := range list {
+		copied[i] = copyExpression(e)
+	}
+	return copied
+}
+
+func copyExpression(e Expression) Expression {
+	if e == nil {
+		return nil
+	}
+	return Copy(e).(Expression)
+}
+
+func copyBlock(b *BlockStatement) *BlockStatement {
+	if b == nil {
+		return nil
+	}
+	return Copy(b).(*BlockStatement)
+}
+
+func copyIdentifiers(list []*Identifier) []*Identifier {
+	if list == nil {
+		return nil
+	}
+	copied := make([]*Identifier, len(list))
+	for i, ident := range list {
+		copied[i] = copyIdentifier(ident)
+	}
+	return copied
+}
+
+func copyIdentifier(i *Identifier) *Identifier {
+	if i == nil {
+		return nil
+	}
+	c := *i
+	return &c
+}
@@ -0,0 +1,11 @@
+package ast
+
+// ModifierFunc returns the node that replaces node.
+type ModifierFunc func(Node) Node
+
+// Modify is Rewrite for macro expansion: it replaces every node of the
+// tree rooted at node, children first, with the result of calling modifier
+// on it.
+func Modify(node Node, modifier ModifierFunc) Node {
+	return Rewrite(node, modifier)
+}
@@ -0,0 +1,341 @@
+package ast
+
+import "fmt"
+
+// A Visitor's Visit method is invoked for each node encountered by Walk.
+// If the result visitor w is not nil, Walk visits each of the children of
+// node with w, followed by a call of w.Visit(nil).
+type Visitor interface {
+	Visit(node Node) (w Visitor)
+}
+
+// Walk traverses the tree rooted at node in depth-first order, children in
+// source order. Optional children that are nil are skipped.
+func Walk(v Visitor, node Node) {
+	if v = v.Visit(node); v == nil {
+		return
+	}
+
+	switch n := node.(type) {
+	case *Program:
+		walkStatements(v, n.Statements)
+
+	case *LetStatement:
+		Walk(v, n.Name)
+		walkExpression(v, n.Value)
+
+	case *ImportStatement:
+		Walk(v, n.Path)
+		Walk(v, n.Alias)
+
+	case *ThrowStatement:
+		walkExpression(v, n.Value)
+
+	case *TryStatement:
+		Walk(v, n.Block)
+		if n.Param != nil {
+			Walk(v, n.Param)
+		}
+		if n.Catch != nil {
+			Walk(v, n.Catch)
+		}
+		if n.Finally != nil {
+			Walk(v, n.Finally)
+		}
+
+	case *ReturnStatement:
+		walkExpression(v, n.ReturnValue)
+
+	case *ExpressionStatement:
+		walkExpression(v, n.Expression)
+
+	case *BlockStatement:
+		walkStatements(v, n.Statements)
+
+	case *Identifier, *Boolean, *NullLiteral, *IntegerLiteral, *StringLiteral:
+		// leaves
+
+	case *PrefixExpression:
+		walkExpression(v, n.Right)
+
+	case *InfixExpression:
+		walkExpression(v, n.Left)
+		walkExpression(v, n.Right)
+
+	case *IfExpression:
+		walkExpression(v, n.Condition)
+		Walk(v, n.Consequence)
+		if n.Alternative != nil {
+			Walk(v, n.Alternative)
+		}
+
+	case *FunctionLiteral:
+		for _, p := range n.Parameters {
+			Walk(v, p)
+			if def, ok := n.Defaults[p.Value]; ok {
+				walkExpression(v, def)
+			}
+		}
+		if n.Rest != nil {
+			Walk(v, n.Rest)
+		}
+		Walk(v, n.Body)
+
+	case *MacroLiteral:
+		for _, p := range n.Parameters {
+			Walk(v, p)
+		}
+		Walk(v, n.Body)
+
+	case *CallExpression:
+		walkExpression(v, n.Function)
+		walkExpressions(v, n.Arguments)
+
+	case *AssignExpression:
+		Walk(v, n.Name)
+		walkExpression(v, n.Value)
+
+	case *PipeExpression:
+		walkExpression(v, n.Left)
+		walkExpression(v, n.Right)
+
+	case *InterpolatedString:
+		walkExpressions(v, n.Parts)
+
+	case *ArrayLiteral:
+		walkExpressions(v, n.Elements)
+
+	case *IndexExpression:
+		walkExpression(v, n.Left)
+		walkExpression(v, n.Index)
+
+	case *HashLiteral:
+		for _, pair := range n.Pairs {
+			walkExpression(v, pair.Key)
+			walkExpression(v, pair.Value)
+		}
+
+	case *SpreadExpression:
+		walkExpression(v, n.Value)
+
+	default:
+		panic(fmt.Sprintf("ast.Walk: unexpected node type %T", n))
+	}
+
+	v.Visit(nil)
+}
+
+func walkStatements(v Visitor, list []Statement) {
+	for _, s := range list {
+		if s != nil {
+			Walk(v, s)
+		}
+	}
+}
+
+func walkExpressions(v Visitor, list []Expression) {
+	for _, e := range list {
+		walkExpression(v, e)
+	}
+}
+
+func walkExpression(v Visitor, e Expression) {
+	if e != nil {
+		Walk(v, e)
+	}
+}
+
+type inspector func(Node) bool
+
+func (f inspector) Visit(node Node) Visitor {
+	if f(node) {
+		return f
+	}
+	return nil
+}
+
+// Inspect traverses the tree rooted at node in depth-first order. It calls
+// f(node) for each node; if f returns true, Inspect visits the children of
+// node, followed by a call of f(nil).
+func Inspect(node Node, f func(Node) bool) {
+	Walk(inspector(f), node)
+}
+
+// Rewrite replaces every node of the tree rooted at node, children first,
+// with the result of calling f on it, and returns the replacement of node
+// itself. The names of members, as in a.name, are left alone. The tree is
+// modified in place. Rewrite panics if f replaces a node with one that
+// cannot take its place, such as a statement with an expression or a
+// parameter with anything but an identifier.
+func Rewrite(node Node, f func(Node) Node) Node {
+	r := rewriter(f)
+	return r.node(node)
+}
+
+type rewriter func(Node) Node
+
+func (r rewriter) node(node Node) Node {
+	switch n := node.(type) {
+	case *Program:
+		r.statements(n.Statements)
+
+	case *LetStatement:
+		n.Name = r.identifier(n.Name)
+		n.Value = r.expression(n.Value)
+
+	case *ImportStatement:
+		path, ok := r.node(n.Path).(*StringLiteral)
+		if !ok {
+			panic("ast.Rewrite: import path replaced with a non-string")
+		}
+		n.Path = path
+		n.Alias = r.identifier(n.Alias)
+
+	case *ThrowStatement:
+		n.Value = r.expression(n.Value)
+
+	case *TryStatement:
+		n.Block = r.block(n.Block)
+		n.Param = r.identifier(n.Param)
+		n.Catch = r.block(n.Catch)
+		n.Finally = r.block(n.Finally)
+
+	case *ReturnStatement:
+		n.ReturnValue = r.expression(n.ReturnValue)
+
+	case *ExpressionStatement:
+		n.Expression = r.expression(n.Expression)
+
+	case *BlockStatement:
+		r.statements(n.Statements)
+
+	case *Identifier, *Boolean, *NullLiteral, *IntegerLiteral, *StringLiteral:
+		// leaves
+
+	case *PrefixExpression:
+		n.Right = r.expression(n.Right)
+
+	case *InfixExpression:
+		n.Left = r.expression(n.Left)
+		n.Right = r.expression(n.Right)
+
+	case *IfExpression:
+		n.Condition = r.expression(n.Condition)
+		n.Consequence = r.block(n.Consequence)
+		n.Alternative = r.block(n.Alternative)
+
+	case *FunctionLiteral:
+		// Defaults are keyed by parameter name, so they follow their
+		// parameter if it is renamed.
+		defaults := make(map[string]Expression, len(n.Defaults))
+		for i, p := range n.Parameters {
+			def, hasDefault := n.Defaults[p.Value]
+			n.Parameters[i] = r.identifier(p)
+			if hasDefault {
+				defaults[n.Parameters[i].Value] = r.expression(def)
+			}
+		}
+		if n.Defaults != nil {
+			n.Defaults = defaults
+		}
+		n.Rest = r.identifier(n.Rest)
+		n.Body = r.block(n.Body)
+
+	case *MacroLiteral:
+		for i, p := range n.Parameters {
+			n.Parameters[i] = r.identifier(p)
+		}
+		n.Body = r.block(n.Body)
+
+	case *CallExpression:
+		n.Function = r.expression(n.Function)
+		r.expressions(n.Arguments)
+
+	case *AssignExpression:
+		n.Name = r.identifier(n.Name)
+		n.Value = r.expression(n.Value)
+
+	case *PipeExpression:
+		n.Left = r.expression(n.Left)
+		n.Right = r.expression(n.Right)
+
+	case *InterpolatedString:
+		r.expressions(n.Parts)
+
+	case *ArrayLiteral:
+		r.expressions(n.Elements)
+
+	case *IndexExpression:
+		n.Left = r.expression(n.Left)
+		// The name of a member is not an expression of the program.
+		if !n.IsMember() {
+			n.Index = r.expression(n.Index)
+		}
+
+	case *HashLiteral:
+		for i, pair := range n.Pairs {
+			n.Pairs[i].Key = r.expression(pair.Key)
+			n.Pairs[i].Value = r.expression(pair.Value)
+		}
+
+	case *SpreadExpression:
+		n.Value = r.expression(n.Value)
+
+	default:
+		panic(fmt.Sprintf("ast.Rewrite: unexpected node type %T", n))
+	}
+
+	return r(node)
+}
+
+func (r rewriter) statements(list []Statement) {
+	for i, s := range list {
+		if s == nil {
+			continue
+		}
+		stmt, ok := r.node(s).(Statement)
+		if !ok {
+			panic(fmt.Sprintf("ast.Rewrite: statement %T replaced with a non-statement", s))
+		}
+		list[i] = stmt
+	}
+}
+
+func (r rewriter) expressions(list []Expression) {
+	for i, e := range list {
+		list[i] = r.expression(e)
+	}
+}
+
+func (r rewriter) expression(e Expression) Expression {
+	if e == nil {
+		return nil
+	}
+	exp, ok := r.node(e).(Expression)
+	if !ok {
+		panic(fmt.Sprintf("ast.Rewrite: expression %T replaced with a non-expression", e))
+	}
+	return exp
+}
+
+func (r rewriter) block(b *BlockStatement) *BlockStatement {
+	if b == nil {
+		return nil
+	}
+	block, ok := r.node(b).(*BlockStatement)
+	if !ok {
+		panic("ast.Rewrite: block statement replaced with a different node")
+	}
+	return block
+}
+
+func (r rewriter) identifier(i *Identifier) *Identifier {
+	if i == nil {
+		return nil
+	}
+	ident, ok := r.node(i).(*Identifier)
+	if !ok {
+		panic(fmt.Sprintf("ast.Rewrite: identifier %s replaced with a non-identifier", i.Value))
+	}
+	return ident
+}
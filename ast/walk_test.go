@@ -0,0 +1,225 @@
+package ast_test
+
+import (
+	"fmt"
+	"monkey/ast"
+	"monkey/lexer"
+	"monkey/parser"
+	"monkey/token"
+	"strings"
+	"testing"
+)
+
+func parse(t *testing.T, input string) *ast.Program {
+	t.Helper()
+
+	p := parser.New(lexer.New(input))
+	program := p.ParseProgram()
+	if len(p.Errors()) != 0 {
+		t.Fatalf("parser errors: %q", p.Errors())
+	}
+	return program
+}
+
+func TestInspect(t *testing.T) {
+	program := parse(t, `let f = fn(a, b = 2, ...c) { try { throw a } catch (e) { e } }; f(1)[0];`)
+
+	var out []string
+	depth := 0
+	ast.Inspect(program, func(node ast.Node) bool {
+		if node == nil {
+			depth--
+			return false
+		}
+		depth++
+		out = append(out, strings.TrimPrefix(fmt.Sprintf("%T", node), "*ast."))
+		return true
+	})
+
+	expected := []string{
+		"Program",
+		"LetStatement", "Identifier",
+		"FunctionLiteral", "Identifier", "Identifier", "IntegerLiteral", "Identifier",
+		"BlockStatement", "TryStatement",
+		"BlockStatement", "ThrowStatement", "Identifier",
+		"Identifier", "BlockStatement", "ExpressionStatement", "Identifier",
+		"ExpressionStatement", "IndexExpression",
+		"CallExpression", "Identifier", "IntegerLiteral", "IntegerLiteral",
+	}
+
+	if strings.Join(out, " ") != strings.Join(expected, " ") {
+		t.Errorf("wrong nodes.\nwant=%q\ngot= %q", expected, out)
+	}
+
+	if depth != 0 {
+		t.Errorf("Inspect did not end every visited node. depth=%d", depth)
+	}
+}
+
+func TestInspectSkipsChildren(t *testing.T) {
+	program := parse(t, `let a = fn() { 1 + 2 }; 3;`)
+
+	ints := 0
+	ast.Inspect(program, func(node ast.Node) bool {
+		if _, ok := node.(*ast.IntegerLiteral); ok {
+			ints++
+		}
+		_, isFn := node.(*ast.FunctionLiteral)
+		return !isFn
+	})
+
+	if ints != 1 {
+		t.Errorf("wrong number of integers outside functions. want=1, got=%d", ints)
+	}
+}
+
+type countingVisitor map[string]int
+
+func (v countingVisitor) Visit(node ast.Node) ast.Visitor {
+	if node != nil {
+		v[strings.TrimPrefix(fmt.Sprintf("%T", node), "*ast.")]++
+	}
+	return v
+}
+
+func TestWalkVisitsEveryNodeType(t *testing.T) {
+	input := `
+	import "m.mk" as m;
+	export const k = null;
+	let s = "a${1}b";
+	let h = {"x": [1, ...xs], true: m.y};
+	macro(q) { q };
+	x = !-1 |> g;
+	if (x) { return 1 } else { 2 };
+	`
+	program := parse(t, input)
+
+	v := countingVisitor{}
+	ast.Walk(v, program)
+
+	for _, name := range []string{
+		"Program", "ImportStatement", "LetStatement", "NullLiteral",
+		"InterpolatedString", "StringLiteral", "HashLiteral", "ArrayLiteral",
+		"SpreadExpression", "Boolean", "IndexExpression", "MacroLiteral",
+		"AssignExpression", "PipeExpression", "PrefixExpression",
+		"IfExpression", "ReturnStatement", "BlockStatement",
+	} {
+		if v[name] == 0 {
+			t.Errorf("Walk did not visit a %s", name)
+		}
+	}
+}
+
+func TestRewrite(t *testing.T) {
+	program := parse(t, `let f = fn(a, b = a) { a + b }; f(1);`)
+
+	ast.Rewrite(program, func(node ast.Node) ast.Node {
+		ident, ok := node.(*ast.Identifier)
+		if !ok || ident.Value == "f" {
+			return node
+		}
+		return &ast.Identifier{Token: ident.Token, Value: ident.Value + "2"}
+	})
+
+	expected := "let f = fn<f>(a2, b2 = a2) (a2 + b2);f(1)"
+	if program.String() != expected {
+		t.Errorf("wrong program. want=%q, got=%q", expected, program.String())
+	}
+}
+
+func TestCopy(t *testing.T) {
+	input := `let f = fn(a, b = a, ...c) { try { [a, {"k": b}][0] } catch (e) { -c?[0] } }; f(1);`
+	program := parse(t, input)
+	original := program.String()
+
+	copied := ast.Copy(program)
+	if copied.String() != original {
+		t.Fatalf("copy differs. want=%q, got=%q", original, copied.String())
+	}
+
+	ast.Rewrite(copied, func(node ast.Node) ast.Node {
+		if ident, ok := node.(*ast.Identifier); ok {
+			return &ast.Identifier{Token: ident.Token, Value: ident.Value + "2"}
+		}
+		return node
+	})
+
+	if program.String() != original {
+		t.Errorf("rewriting the copy changed the original. got=%q", program.String())
+	}
+	if copied.String() == original {
+		t.Errorf("copy was not rewritten")
+	}
+}
+
+func TestRewriteHashKeys(t *testing.T) {
+	program := parse(t, `{"a": "b", "c": "d"}`)
+
+	ast.Rewrite(program, func(node ast.Node) ast.Node {
+		str, ok := node.(*ast.StringLiteral)
+		if !ok {
+			return node
+		}
+		value := strings.ToUpper(str.Value)
+		return &ast.StringLiteral{Token: token.Token{Type: token.STRING, Literal: value}, Value: value}
+	})
+
+	hash := program.Statements[0].(*ast.ExpressionStatement).Expression.(*ast.HashLiteral)
+	if len(hash.Pairs) != 2 {
+		t.Fatalf("wrong number of pairs. got=%d", len(hash.Pairs))
+	}
+
+	if hash.String() != "{A:B, C:D}" {
+		t.Errorf("hash keys and values not rewritten. got=%q", hash.String())
+	}
+}
+
+func TestRewriteSkipsMemberNames(t *testing.T) {
+	program := parse(t, `m.name + h["name"];`)
+
+	ast.Rewrite(program, func(node ast.Node) ast.Node {
+		if str, ok := node.(*ast.StringLiteral); ok {
+			value := strings.ToUpper(str.Value)
+			return &ast.StringLiteral{Token: token.Token{Type: token.STRING, Literal: value}, Value: value}
+		}
+		return node
+	})
+
+	expected := "((m.name) + (h[NAME]))"
+	if program.String() != expected {
+		t.Errorf("wrong program. want=%q, got=%q", expected, program.String())
+	}
+}
+
+func TestRewriteInterpolatedString(t *testing.T) {
+	program := parse(t, `"a ${x} b ${"c ${x}"}";`)
+
+	ast.Rewrite(program, func(node ast.Node) ast.Node {
+		if ident, ok := node.(*ast.Identifier); ok {
+			return &ast.Identifier{Token: ident.Token, Value: ident.Value + "2"}
+		}
+		return node
+	})
+
+	expected := "a ${x2} b ${c ${x2}}"
+	if program.String() != expected {
+		t.Errorf("wrong program. want=%q, got=%q", expected, program.String())
+	}
+}
+
+func TestRewritePanicsOnMisplacedNode(t *testing.T) {
+	program := parse(t, `let a = 1;`)
+
+	defer func() {
+		if r := recover(); r == nil {
+			t.Errorf("Rewrite did not panic")
+		}
+	}()
+
+	ast.Rewrite(program, func(node ast.Node) ast.Node {
+		if _, ok := node.(*ast.Identifier); ok {
+			return &ast.IntegerLiteral{Value: 1}
+		}
+		return node
+	})
+}
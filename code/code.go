@@ -4,6 +4,7 @@ import (
 	"bytes"
 	"encoding/binary"
 	"fmt"
+	"sort"
 )
 
 type Instructions []byte
@@ -30,8 +31,62 @@ const (
 	OpGetGlobal
 	OpSetGlobal
 	OpArray
+	OpCall
+	OpReturnValue
+	OpReturn
+	OpGetLocal
+	OpSetLocal
+	OpClosure
+	OpGetFree
+	OpCurrentClosure
+	// OpJumpIfPassed jumps when the current call received the argument for
+	// the given parameter, skipping the code that computes its default.
+	OpJumpIfPassed
+	// OpConcat joins the given number of arrays on the stack into one.
+	OpConcat
+	// OpCallSpread calls the function below an array, passing the array's
+	// elements as arguments.
+	OpCallSpread
+	// OpInterpolate converts the given number of values on the stack to
+	// strings and joins them.
+	OpInterpolate
+	OpHash
+	OpIndex
+	// OpJumpNull jumps when the value on top of the stack is null. The
+	// value stays on the stack either way.
+	OpJumpNull
+	// OpThrow raises the value on top of the stack as an error.
+	OpThrow
+	// OpSetupHandler installs a handler that catches errors raised until
+	// the matching OpPopHandler. It unwinds the frames and the stack to
+	// where they were when it was installed, pushes the error hash and
+	// jumps to the given address.
+	OpSetupHandler
+	OpPopHandler
+	OpGetBuiltin
 )
 
+// LineEntry maps the instructions from Offset up to the next entry to the
+// source line they were compiled from.
+type LineEntry struct {
+	Offset int
+	Line   int
+}
+
+// LineTable maps instruction offsets to source lines. Its entries are
+// sorted by offset.
+type LineTable []LineEntry
+
+// LineAt returns the source line of the instruction at offset, or 0 if
+// it is not known.
+func (t LineTable) LineAt(offset int) int {
+	i := sort.Search(len(t), func(i int) bool { return t[i].Offset > offset })
+	if i == 0 {
+		return 0
+	}
+	return t[i-1].Line
+}
+
 type Definition struct {
 	Name          string
 	OperandWidths []int
@@ -57,6 +112,25 @@ var definitions = map[Opcode]*Definition{
 	OpGetGlobal:        {"OpGetGlobal", []int{2}},
 	OpSetGlobal:        {"OpSetGlobal", []int{2}},
 	OpArray:            {"OpArray", []int{2}},
+	OpCall:             {"OpCall", []int{1}},
+	OpReturnValue:      {"OpReturnValue", []int{}},
+	OpReturn:           {"OpReturn", []int{}},
+	OpGetLocal:         {"OpGetLocal", []int{1}},
+	OpSetLocal:         {"OpSetLocal", []int{1}},
+	OpClosure:          {"OpClosure", []int{2, 1}},
+	OpGetFree:          {"OpGetFree", []int{1}},
+	OpCurrentClosure:   {"OpCurrentClosure", []int{}},
+	OpJumpIfPassed:     {"OpJumpIfPassed", []int{1, 2}},
+	OpConcat:           {"OpConcat", []int{2}},
+	OpCallSpread:       {"OpCallSpread", []int{}},
+	OpInterpolate:      {"OpInterpolate", []int{2}},
+	OpHash:             {"OpHash", []int{2}},
+	OpIndex:            {"OpIndex", []int{}},
+	OpJumpNull:         {"OpJumpNull", []int{2}},
+	OpThrow:            {"OpThrow", []int{}},
+	OpSetupHandler:     {"OpSetupHandler", []int{2}},
+	OpPopHandler:       {"OpPopHandler", []int{}},
+	OpGetBuiltin:       {"OpGetBuiltin", []int{1}},
 }
 
 func Lookup(op byte) (*Definition, error) {
@@ -97,6 +171,8 @@ func Make(op Opcode, operands ...int) []byte {
 		switch width {
 		case 2:
 			binary.BigEndian.PutUint16(instruction[offset:], uint16(o))
+		case 1:
+			instruction[offset] = byte(o)
 		}
 
 		offset += width
@@ -136,6 +212,8 @@ func ReadOperands(def *Definition, ins Instructions) ([]int, int) {
 		switch width {
 		case 2:
 			operands[i] = int(ReadUint16(ins[offset:]))
+		case 1:
+			operands[i] = int(ReadUint8(ins[offset:]))
 		}
 
 		offset += width
@@ -148,6 +226,10 @@ func ReadUint16(ins Instructions) uint16 {
 	return binary.BigEndian.Uint16(ins)
 }
 
+func ReadUint8(ins Instructions) uint8 {
+	return uint8(ins[0])
+}
+
 func (int Instructions) fmtInstruction(def *Definition, operands []int) string {
 	operandCount := len(def.OperandWidths)
 
@@ -160,6 +242,8 @@ func (int Instructions) fmtInstruction(def *Definition, operands []int) string {
 		return fmt.Sprintf("%s", def.Name)
 	case 1:
 		return fmt.Sprintf("%s %d", def.Name, operands[0])
+	case 2:
+		return fmt.Sprintf("%s %d %d", def.Name, operands[0], operands[1])
 	}
 
 	return fmt.Sprintf("ERROR: unhandled operandCount for %s\n", def.Name)
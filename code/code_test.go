@@ -25,6 +25,16 @@ func TestMake(t *testing.T) {
 			[]int{},
 			[]byte{byte(OpPop)},
 		},
+		{
+			OpGetLocal,
+			[]int{255},
+			[]byte{byte(OpGetLocal), 255},
+		},
+		{
+			OpClosure,
+			[]int{65534, 255},
+			[]byte{byte(OpClosure), 255, 254, 255},
+		},
 	}
 
 	for _, tt := range tests {
@@ -52,6 +62,8 @@ func TestInstructionsString(t *testing.T) {
 		Make(OpConstant, 65535),
 		Make(OpAdd),
 		Make(OpPop),
+		Make(OpGetLocal, 1),
+		Make(OpClosure, 65535, 255),
 	}
 
 	expected := `0000 OpConstant 1
@@ -59,6 +71,8 @@ func TestInstructionsString(t *testing.T) {
 0006 OpConstant 65535
 0009 OpAdd
 0010 OpPop
+0011 OpGetLocal 1
+0013 OpClosure 65535 255
 `
 
 	concatted := Instructions{}
@@ -80,6 +94,11 @@ func TestReadOperands(t *testing.T) {
 	}{
 		{OpConstant, []int{65535}, 2},
 		{OpAdd, []int{}, 0},
+		{OpGetLocal, []int{255}, 1},
+		{OpClosure, []int{65535, 255}, 3},
+		{OpSetupHandler, []int{65535}, 2},
+		{OpGetBuiltin, []int{255}, 1},
+		{OpJumpIfPassed, []int{2, 300}, 3},
 	}
 
 	for _, tt := range tests {
@@ -106,3 +125,29 @@ func TestReadOperands(t *testing.T) {
 	}
 
 }
+
+func TestLineAt(t *testing.T) {
+	lines := LineTable{{Offset: 0, Line: 1}, {Offset: 7, Line: 3}, {Offset: 12, Line: 4}}
+
+	tests := []struct {
+		offset   int
+		expected int
+	}{
+		{0, 1},
+		{6, 1},
+		{7, 3},
+		{11, 3},
+		{12, 4},
+		{100, 4},
+	}
+
+	for _, tt := range tests {
+		if got := lines.LineAt(tt.offset); got != tt.expected {
+			t.Errorf("LineAt(%d) wrong. want=%d, got=%d", tt.offset, tt.expected, got)
+		}
+	}
+
+	if got := (LineTable{}).LineAt(0); got != 0 {
+		t.Errorf("LineAt on an empty table wrong. want=0, got=%d", got)
+	}
+}
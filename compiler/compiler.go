@@ -4,15 +4,34 @@ import (
 	"fmt"
 	"monkey/ast"
 	"monkey/code"
+	"monkey/module"
 	"monkey/object"
 )
 
 type Compiler struct {
-	instructions        code.Instructions
-	constants           []object.Object
-	lastInstruction     EmittedInstruction
-	previousInstruction EmittedInstruction
-	symbolTable         *SymbolTable
+	constants   []object.Object
+	symbolTable *SymbolTable
+
+	scopes     []CompilationScope
+	scopeIndex int
+
+	loader *module.Loader
+
+	// module is set while the top level of an imported module is being
+	// compiled.
+	module *moduleCode
+
+	// chain is set while compiling the left side of a call or index
+	// expression that is itself one, and holds the jumps the chain takes
+	// when an optional index finds null.
+	chain *[]int
+}
+
+// moduleCode is an imported module being compiled into the importer's
+// instructions. A return at its top level jumps past the module's code.
+type moduleCode struct {
+	scope int
+	exits []int
 }
 
 type EmittedInstruction struct {
@@ -20,14 +39,46 @@ type EmittedInstruction struct {
 	Position int
 }
 
-func New() *Compiler {
+// CompilationScope holds the instructions of the function being compiled.
+type CompilationScope struct {
+	instructions        code.Instructions
+	lastInstruction     EmittedInstruction
+	previousInstruction EmittedInstruction
 
-	return &Compiler{
+	lines code.LineTable
+	line  int // line of the statement being compiled
+
+	// tries holds the try statements the code being compiled is nested
+	// in, innermost last.
+	tries []tryContext
+}
+
+// tryContext is what a return has to undo when it leaves a try statement:
+// the handler covering the code, if any, and the finally block to run.
+type tryContext struct {
+	handler bool
+	finally *ast.BlockStatement
+}
+
+func New() *Compiler {
+	mainScope := CompilationScope{
 		instructions:        code.Instructions{},
-		constants:           []object.Object{},
 		lastInstruction:     EmittedInstruction{},
 		previousInstruction: EmittedInstruction{},
-		symbolTable:         NewSymbolTable(),
+	}
+
+	symbolTable := NewSymbolTable()
+
+	for i, v := range object.Builtins {
+		symbolTable.DefineBuiltin(i, v.Name)
+	}
+
+	return &Compiler{
+		constants:   []object.Object{},
+		symbolTable: symbolTable,
+		scopes:      []CompilationScope{mainScope},
+		scopeIndex:  0,
+		loader:      module.NewLoader("."),
 	}
 }
 
@@ -41,7 +92,19 @@ func NewWithState(s *SymbolTable, constants []object.Object) *Compiler {
 
 }
 
+// SetLoader sets the loader used to resolve and parse imported modules.
+func (c *Compiler) SetLoader(l *module.Loader) {
+	c.loader = l
+}
+
 func (c *Compiler) Compile(node ast.Node) error {
+	if s, ok := node.(ast.Statement); ok {
+		if _, ok := s.(*ast.BlockStatement); !ok {
+			prev := c.setLine(ast.StatementLine(s))
+			defer c.setLine(prev)
+		}
+	}
+
 	switch node := node.(type) {
 	case *ast.Program:
 		for _, s := range node.Statements {
@@ -63,14 +126,103 @@ func (c *Compiler) Compile(node ast.Node) error {
 		c.emit(code.OpPop)
 
 	case *ast.LetStatement:
+		if c.symbolTable.IsConstant(node.Name.Value) {
+			return fmt.Errorf("cannot redeclare constant %s", node.Name.Value)
+		}
+
 		err := c.Compile(node.Value)
 		if err != nil {
 			return err
 		}
-		symbol := c.symbolTable.Define(node.Name.Value)
-		c.emit(code.OpSetGlobal, symbol.Index)
+
+		if c.symbolTable.IsCaptured(node.Name.Value) {
+			return fmt.Errorf("cannot redeclare captured variable %s", node.Name.Value)
+		}
+
+		var symbol Symbol
+		if node.IsConst() {
+			symbol = c.symbolTable.DefineConstant(node.Name.Value)
+		} else {
+			symbol = c.symbolTable.Define(node.Name.Value)
+		}
+		c.storeSymbol(symbol)
+
+	case *ast.ImportStatement:
+		err := c.compileImport(node)
+		if err != nil {
+			return err
+		}
+
+	case *ast.AssignExpression:
+		symbol, ok := c.symbolTable.Resolve(node.Name.Value)
+		if !ok {
+			return fmt.Errorf("Cannot find symbol %s", node.Name.Value)
+		}
+
+		if symbol.Constant {
+			return fmt.Errorf("cannot assign to constant %s", node.Name.Value)
+		}
+
+		if symbol.Scope == BuiltinScope {
+			return fmt.Errorf("cannot assign to builtin %s", node.Name.Value)
+		}
+
+		// Closures capture free variables by value, so writing to one would
+		// not be seen by the enclosing function.
+		if symbol.Scope == FreeScope || symbol.Scope == FunctionScope {
+			return fmt.Errorf("cannot assign to captured variable %s", node.Name.Value)
+		}
+
+		err := c.Compile(node.Value)
+		if err != nil {
+			return err
+		}
+
+		// Neither can a local that a closure has already copied, possibly
+		// in the value itself.
+		if c.symbolTable.isCaptured(symbol) {
+			return fmt.Errorf("cannot assign to captured variable %s", node.Name.Value)
+		}
+
+		c.storeSymbol(symbol)
+		c.loadSymbol(symbol)
+
+	case *ast.ReturnStatement:
+		err := c.Compile(node.ReturnValue)
+		if err != nil {
+			return err
+		}
+
+		err = c.leaveTries()
+		if err != nil {
+			return err
+		}
+
+		if c.module != nil && c.module.scope == c.scopeIndex {
+			c.emit(code.OpPop)
+			c.module.exits = append(c.module.exits, c.emit(code.OpJump, 9999))
+			return nil
+		}
+
+		c.emit(code.OpReturnValue)
+
+	case *ast.ThrowStatement:
+		err := c.Compile(node.Value)
+		if err != nil {
+			return err
+		}
+
+		c.emit(code.OpThrow)
+
+	case *ast.TryStatement:
+		err := c.compileTry(node)
+		if err != nil {
+			return err
+		}
 
 	case *ast.BlockStatement:
+		c.symbolTable = NewBlockSymbolTable(c.symbolTable)
+
 		for _, s := range node.Statements {
 
 			err := c.Compile(s)
@@ -80,12 +232,17 @@ func (c *Compiler) Compile(node ast.Node) error {
 			}
 		}
 
+		c.symbolTable = c.symbolTable.Outer
+
 	case *ast.Identifier:
 		symbol, ok := c.symbolTable.Resolve(node.Value)
 		if !ok {
 			return fmt.Errorf("Cannot find symbol %s", node.Value)
 		}
-		c.emit(code.OpGetGlobal, symbol.Index)
+		if symbol.Scope == ModuleScope {
+			return fmt.Errorf("module %s can only be used to access its exports", node.Value)
+		}
+		c.loadSymbol(symbol)
 
 	case *ast.IfExpression:
 		err := c.Compile(node.Condition)
@@ -101,18 +258,16 @@ func (c *Compiler) Compile(node ast.Node) error {
 			return err
 		}
 
-		if c.lastInstructionIsPop() {
-			c.removeLastPop()
-		}
+		c.keepBlockValue()
 
 		jumpPos := c.emit(code.OpJump, 9999)
-		afterConsequencePos := len(c.instructions)
+		afterConsequencePos := len(c.currentInstructions())
 		c.changeOperand(jumpNotTruthyPos, afterConsequencePos)
 
 		if node.Alternative == nil {
 
 			c.emit(code.OpNull)
-			afterAlternativePos := len(c.instructions)
+			afterAlternativePos := len(c.currentInstructions())
 
 			c.changeOperand(jumpPos, afterAlternativePos)
 
@@ -123,11 +278,9 @@ func (c *Compiler) Compile(node ast.Node) error {
 			if err != nil {
 				return err
 			}
-			if c.lastInstructionIsPop() {
-				c.removeLastPop()
-			}
+			c.keepBlockValue()
 
-			afterAlternativePos := len(c.instructions)
+			afterAlternativePos := len(c.currentInstructions())
 
 			c.changeOperand(jumpPos, afterAlternativePos)
 
@@ -150,6 +303,27 @@ func (c *Compiler) Compile(node ast.Node) error {
 		}
 
 	case *ast.InfixExpression:
+		if node.Operator == "??" {
+			err := c.Compile(node.Left)
+			if err != nil {
+				return err
+			}
+
+			jumpNullPos := c.emit(code.OpJumpNull, 9999)
+			jumpPos := c.emit(code.OpJump, 9999)
+
+			c.changeOperand(jumpNullPos, len(c.currentInstructions()))
+			c.emit(code.OpPop)
+
+			err = c.Compile(node.Right)
+			if err != nil {
+				return err
+			}
+
+			c.changeOperand(jumpPos, len(c.currentInstructions()))
+			return nil
+		}
+
 		if node.Operator == "<" {
 			err := c.Compile(node.Right)
 			if err != nil {
@@ -200,10 +374,24 @@ func (c *Compiler) Compile(node ast.Node) error {
 		str := &object.String{Value: node.Value}
 		c.emit(code.OpConstant, c.addConstant((str)))
 
+	case *ast.InterpolatedString:
+		for _, part := range node.Parts {
+			err := c.Compile(part)
+			if err != nil {
+				return err
+			}
+		}
+
+		c.emit(code.OpInterpolate, len(node.Parts))
+
 	case *ast.IntegerLiteral:
 		integer := &object.Integer{Value: node.Value}
 		c.emit(code.OpConstant, c.addConstant(integer))
 	case *ast.ArrayLiteral:
+		if hasSpread(node.Elements) {
+			return c.compileSpreadList(node.Elements)
+		}
+
 		numElements := len(node.Elements)
 
 		for _, element := range node.Elements {
@@ -215,6 +403,108 @@ func (c *Compiler) Compile(node ast.Node) error {
 
 		c.emit(code.OpArray, numElements)
 
+	case *ast.FunctionLiteral:
+		c.enterScope()
+
+		if node.Name != "" {
+			c.symbolTable.DefineFunctionName(node.Name)
+		}
+
+		for _, p := range node.Parameters {
+			c.symbolTable.Define(p.Value)
+		}
+
+		if node.Rest != nil {
+			c.symbolTable.Define(node.Rest.Value)
+		}
+
+		for i, p := range node.Parameters {
+			def, ok := node.Defaults[p.Value]
+			if !ok {
+				continue
+			}
+
+			jumpPos := c.emit(code.OpJumpIfPassed, i, 9999)
+
+			// A default only sees the parameters before it: the slots of
+			// the others are still empty when it runs.
+			unbound := []string{}
+			for _, later := range node.Parameters[i:] {
+				unbound = append(unbound, later.Value)
+			}
+			if node.Rest != nil {
+				unbound = append(unbound, node.Rest.Value)
+			}
+			hidden := c.symbolTable.hide(unbound)
+			err := c.Compile(def)
+			c.symbolTable.unhide(hidden)
+			if err != nil {
+				return err
+			}
+
+			c.emit(code.OpSetLocal, i)
+			c.changeOperand(jumpPos, i, len(c.currentInstructions()))
+		}
+
+		err := c.Compile(node.Body)
+		if err != nil {
+			return err
+		}
+
+		if c.lastInstructionIs(code.OpPop) {
+			c.replaceLastPopWithReturn()
+		}
+		if !c.lastInstructionIs(code.OpReturnValue) {
+			c.emit(code.OpReturn)
+		}
+
+		freeSymbols := c.symbolTable.FreeSymbols
+		numLocals := c.symbolTable.numDefinitions
+		lines := c.scopes[c.scopeIndex].lines
+		instructions := c.leaveScope()
+
+		for _, s := range freeSymbols {
+			c.loadSymbol(s)
+		}
+
+		compiledFn := &object.CompiledFunction{
+			Instructions:  instructions,
+			NumLocals:     numLocals,
+			NumParameters: len(node.Parameters),
+			NumRequired:   len(node.Parameters) - len(node.Defaults),
+			Variadic:      node.Rest != nil,
+			Lines:         lines,
+		}
+
+		fnIndex := c.addConstant(compiledFn)
+		c.emit(code.OpClosure, fnIndex, len(freeSymbols))
+
+	case *ast.PipeExpression:
+		return c.Compile(node.Call())
+
+	case *ast.MacroLiteral:
+		return fmt.Errorf("macros can only be defined by top-level let statements")
+
+	case *ast.CallExpression:
+		return c.compileChain(node)
+
+	case *ast.HashLiteral:
+		for _, pair := range node.Pairs {
+			err := c.Compile(pair.Key)
+			if err != nil {
+				return err
+			}
+			err = c.Compile(pair.Value)
+			if err != nil {
+				return err
+			}
+		}
+
+		c.emit(code.OpHash, len(node.Pairs)*2)
+
+	case *ast.IndexExpression:
+		return c.compileChain(node)
+
 	case *ast.Boolean:
 		if node.Value {
 			c.emit(code.OpTrue)
@@ -222,52 +512,501 @@ func (c *Compiler) Compile(node ast.Node) error {
 			c.emit(code.OpFalse)
 		}
 
+	case *ast.NullLiteral:
+		c.emit(code.OpNull)
+
 	}
 	return nil
 }
 
+// compileSpreadList builds a single array out of a list that contains
+// spread elements: runs of plain elements become arrays of their own and
+// are concatenated with the spread operands.
+func (c *Compiler) compileSpreadList(elements []ast.Expression) error {
+	numParts := 0
+	pending := 0
+
+	flush := func() {
+		if pending > 0 {
+			c.emit(code.OpArray, pending)
+			numParts++
+			pending = 0
+		}
+	}
+
+	for _, element := range elements {
+		if spread, ok := element.(*ast.SpreadExpression); ok {
+			flush()
+
+			err := c.Compile(spread.Value)
+			if err != nil {
+				return err
+			}
+			numParts++
+			continue
+		}
+
+		err := c.Compile(element)
+		if err != nil {
+			return err
+		}
+		pending++
+	}
+
+	flush()
+	c.emit(code.OpConcat, numParts)
+
+	return nil
+}
+
+func hasSpread(elements []ast.Expression) bool {
+	for _, element := range elements {
+		if _, ok := element.(*ast.SpreadExpression); ok {
+			return true
+		}
+	}
+	return false
+}
+
+func (c *Compiler) loadSymbol(s Symbol) {
+	switch s.Scope {
+	case GlobalScope:
+		c.emit(code.OpGetGlobal, s.Index)
+	case LocalScope:
+		c.emit(code.OpGetLocal, s.Index)
+	case FreeScope:
+		c.emit(code.OpGetFree, s.Index)
+	case FunctionScope:
+		c.emit(code.OpCurrentClosure)
+	case BuiltinScope:
+		c.emit(code.OpGetBuiltin, s.Index)
+	}
+}
+
+// compileImport compiles the imported module into the current
+// instructions the first time its file is imported, with its own global
+// table so its names do not clash with the importer's, and binds the alias.
+// A return at the top level of the module only ends the module.
+func (c *Compiler) compileImport(node *ast.ImportStatement) error {
+	alias := node.Alias.Value
+	if c.symbolTable.IsConstant(alias) {
+		return fmt.Errorf("cannot redeclare constant %s", alias)
+	}
+
+	file, err := c.loader.Resolve(node.Path.Value)
+	if err != nil {
+		return err
+	}
+
+	index, ok := c.symbolTable.LookupModule(file)
+	if !ok {
+		if err := c.loader.Enter(file); err != nil {
+			return err
+		}
+		defer c.loader.Leave()
+
+		program, err := c.loader.Parse(file)
+		if err != nil {
+			return err
+		}
+
+		importer := c.symbolTable
+		mod := NewModuleSymbolTable(importer.globalTable(), node.Path.Value, file)
+
+		scope := &c.scopes[c.scopeIndex]
+		importing, tries := c.module, scope.tries
+		c.module, scope.tries = &moduleCode{scope: c.scopeIndex}, nil
+
+		c.symbolTable = mod
+		err = c.Compile(program)
+		c.symbolTable = importer
+		exits := c.module.exits
+		c.module, c.scopes[c.scopeIndex].tries = importing, tries
+		if err != nil {
+			return err
+		}
+
+		for _, pos := range exits {
+			c.changeOperand(pos, len(c.currentInstructions()))
+		}
+
+		index = c.symbolTable.AddModule(mod, module.Exports(program))
+	}
+
+	c.symbolTable.DefineModule(alias, index)
+	return nil
+}
+
+// compileChain compiles a call or index expression. An optional index that
+// finds null skips the rest of the chain of calls and indexes it belongs
+// to, so its jump is patched by the outermost expression of the chain.
+func (c *Compiler) compileChain(node ast.Expression) error {
+	chain := c.chain
+	c.chain = nil
+
+	outermost := chain == nil
+	if outermost {
+		chain = &[]int{}
+	}
+
+	var err error
+	switch node := node.(type) {
+	case *ast.CallExpression:
+		err = c.compileCall(node, chain)
+	case *ast.IndexExpression:
+		err = c.compileIndex(node, chain)
+	}
+	if err != nil {
+		return err
+	}
+
+	if outermost {
+		for _, pos := range *chain {
+			c.changeOperand(pos, len(c.currentInstructions()))
+		}
+	}
+	return nil
+}
+
+// compileChainLeft compiles the left side of a call or index expression,
+// which continues its chain if it is a call or index expression too.
+func (c *Compiler) compileChainLeft(left ast.Expression, chain *[]int) error {
+	switch left.(type) {
+	case *ast.CallExpression, *ast.IndexExpression:
+		c.chain = chain
+	}
+	return c.Compile(left)
+}
+
+func (c *Compiler) compileCall(node *ast.CallExpression, chain *[]int) error {
+	if node.Function.TokenLiteral() == "quote" {
+		return fmt.Errorf("quote can only be used inside macros")
+	}
+
+	err := c.compileChainLeft(node.Function, chain)
+	if err != nil {
+		return err
+	}
+
+	if hasSpread(node.Arguments) {
+		err := c.compileSpreadList(node.Arguments)
+		if err != nil {
+			return err
+		}
+
+		c.emit(code.OpCallSpread)
+		return nil
+	}
+
+	for _, a := range node.Arguments {
+		err := c.Compile(a)
+		if err != nil {
+			return err
+		}
+	}
+
+	c.emit(code.OpCall, len(node.Arguments))
+	return nil
+}
+
+func (c *Compiler) compileIndex(node *ast.IndexExpression, chain *[]int) error {
+	if ident, ok := node.Left.(*ast.Identifier); ok {
+		symbol, ok := c.symbolTable.Resolve(ident.Value)
+		if ok && symbol.Scope == ModuleScope {
+			return c.compileModuleMember(symbol, node.Index)
+		}
+	}
+
+	err := c.compileChainLeft(node.Left, chain)
+	if err != nil {
+		return err
+	}
+
+	if node.Optional {
+		*chain = append(*chain, c.emit(code.OpJumpNull, 9999))
+	}
+
+	err = c.Compile(node.Index)
+	if err != nil {
+		return err
+	}
+
+	c.emit(code.OpIndex)
+	return nil
+}
+
+func (c *Compiler) compileModuleMember(m Symbol, index ast.Expression) error {
+	name, ok := index.(*ast.StringLiteral)
+	if !ok {
+		return fmt.Errorf("index operator not supported: %s", object.MODULE_OBJ)
+	}
+
+	symbol, err := c.symbolTable.ResolveExport(m, name.Value)
+	if err != nil {
+		return err
+	}
+
+	c.loadSymbol(symbol)
+	return nil
+}
+
+// compileTry compiles a try statement. The try block runs under a handler
+// that jumps to the catch block; with a finally block, the catch block
+// runs under a second handler, and errors that are not caught run the
+// finally block before being thrown again:
+//
+//	OpSetupHandler catch
+//	<try block>
+//	OpPopHandler
+//	OpJump end
+//	catch:
+//	OpSetupHandler rethrow
+//	<bind the error, catch block>
+//	OpPopHandler
+//	OpJump end
+//	rethrow:
+//	<finally block>
+//	OpThrow
+//	end:
+//	<finally block>
+func (c *Compiler) compileTry(node *ast.TryStatement) error {
+	setupPos := c.emit(code.OpSetupHandler, 9999)
+
+	err := c.compileTryBlock(node.Block, tryContext{handler: true, finally: node.Finally})
+	if err != nil {
+		return err
+	}
+
+	c.emit(code.OpPopHandler)
+	jumps := []int{c.emit(code.OpJump, 9999)}
+
+	c.changeOperand(setupPos, len(c.currentInstructions()))
+
+	if node.Catch != nil {
+		rethrowPos := -1
+		if node.Finally != nil {
+			rethrowPos = c.emit(code.OpSetupHandler, 9999)
+		}
+
+		c.symbolTable = NewBlockSymbolTable(c.symbolTable)
+		if node.Param != nil {
+			c.storeSymbol(c.symbolTable.Define(node.Param.Value))
+		} else {
+			c.emit(code.OpPop)
+		}
+
+		err := c.compileTryBlock(node.Catch, tryContext{
+			handler: node.Finally != nil,
+			finally: node.Finally,
+		})
+		c.symbolTable = c.symbolTable.Outer
+		if err != nil {
+			return err
+		}
+
+		if node.Finally != nil {
+			c.emit(code.OpPopHandler)
+			jumps = append(jumps, c.emit(code.OpJump, 9999))
+			c.changeOperand(rethrowPos, len(c.currentInstructions()))
+		}
+	}
+
+	if node.Finally != nil {
+		err := c.Compile(node.Finally)
+		if err != nil {
+			return err
+		}
+		c.emit(code.OpThrow)
+	}
+
+	for _, pos := range jumps {
+		c.changeOperand(pos, len(c.currentInstructions()))
+	}
+
+	if node.Finally != nil {
+		err := c.Compile(node.Finally)
+		if err != nil {
+			return err
+		}
+	}
+
+	// The code above is entered by jumps, so its last instruction must not
+	// be taken for the value of the statement.
+	c.scopes[c.scopeIndex].lastInstruction = EmittedInstruction{}
+	c.scopes[c.scopeIndex].previousInstruction = EmittedInstruction{}
+
+	return nil
+}
+
+func (c *Compiler) compileTryBlock(block *ast.BlockStatement, ctx tryContext) error {
+	scope := &c.scopes[c.scopeIndex]
+	scope.tries = append(scope.tries, ctx)
+
+	err := c.Compile(block)
+
+	scope = &c.scopes[c.scopeIndex]
+	scope.tries = scope.tries[:len(scope.tries)-1]
+	return err
+}
+
+// leaveTries emits the code a return runs before it leaves the try
+// statements of the current function: it removes their handlers and runs
+// their finally blocks, innermost first.
+func (c *Compiler) leaveTries() error {
+	scope := &c.scopes[c.scopeIndex]
+	tries := scope.tries
+	defer func() { c.scopes[c.scopeIndex].tries = tries }()
+
+	for i := len(tries) - 1; i >= 0; i-- {
+		if tries[i].handler {
+			c.emit(code.OpPopHandler)
+		}
+
+		if tries[i].finally != nil {
+			// A return inside the finally block only leaves the outer ones.
+			c.scopes[c.scopeIndex].tries = tries[:i]
+
+			err := c.Compile(tries[i].finally)
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// setLine records that the instructions emitted from now on come from
+// line, and returns the line recorded before.
+func (c *Compiler) setLine(line int) int {
+	scope := &c.scopes[c.scopeIndex]
+	prev := scope.line
+	scope.line = line
+	return prev
+}
+
+// addLine maps the instruction at pos to the current line. Instructions
+// emitted outside of any statement keep the line of the one before.
+func (c *Compiler) addLine(pos int) {
+	scope := &c.scopes[c.scopeIndex]
+	n := len(scope.lines)
+	if scope.line != 0 && (n == 0 || scope.lines[n-1].Line != scope.line) {
+		scope.lines = append(scope.lines, code.LineEntry{Offset: pos, Line: scope.line})
+	}
+}
+
+func (c *Compiler) storeSymbol(s Symbol) {
+	if s.Scope == GlobalScope {
+		c.emit(code.OpSetGlobal, s.Index)
+	} else {
+		c.emit(code.OpSetLocal, s.Index)
+	}
+}
+
 func (c *Compiler) emit(op code.Opcode, operands ...int) int {
 	ins := code.Make(op, operands...)
 	pos := c.addInstruction(ins)
+	c.addLine(pos)
 
 	c.setLastInstruction(op, pos)
 	return pos
 }
 
+func (c *Compiler) currentInstructions() code.Instructions {
+	return c.scopes[c.scopeIndex].instructions
+}
+
 func (c *Compiler) addInstruction(ins []byte) int {
-	posNewInstruction := len(c.instructions)
-	c.instructions = append(c.instructions, ins...)
+	posNewInstruction := len(c.currentInstructions())
+	updatedInstructions := append(c.currentInstructions(), ins...)
+
+	c.scopes[c.scopeIndex].instructions = updatedInstructions
+
 	return posNewInstruction
 }
 
 func (c *Compiler) replaceInstruction(pos int, newInstruction []byte) {
+	ins := c.currentInstructions()
+
 	for i := 0; i < len(newInstruction); i++ {
-		c.instructions[pos+i] = newInstruction[i]
+		ins[pos+i] = newInstruction[i]
 	}
 }
 
-func (c *Compiler) changeOperand(opPos int, operand int) {
-	op := code.Opcode(c.instructions[opPos])
-	newInstruction := code.Make(op, operand)
+func (c *Compiler) changeOperand(opPos int, operands ...int) {
+	op := code.Opcode(c.currentInstructions()[opPos])
+	newInstruction := code.Make(op, operands...)
 
 	c.replaceInstruction(opPos, newInstruction)
 
 }
 
 func (c *Compiler) setLastInstruction(op code.Opcode, pos int) {
-	previous := c.lastInstruction
+	previous := c.scopes[c.scopeIndex].lastInstruction
 	last := EmittedInstruction{Opcode: op, Position: pos}
-	c.previousInstruction = previous
-	c.lastInstruction = last
+
+	c.scopes[c.scopeIndex].previousInstruction = previous
+	c.scopes[c.scopeIndex].lastInstruction = last
 }
 
-func (c *Compiler) lastInstructionIsPop() bool {
-	return c.lastInstruction.Opcode == code.OpPop
+func (c *Compiler) lastInstructionIs(op code.Opcode) bool {
+	if len(c.currentInstructions()) == 0 {
+		return false
+	}
+
+	return c.scopes[c.scopeIndex].lastInstruction.Opcode == op
 }
 
 func (c *Compiler) removeLastPop() {
-	c.instructions = c.instructions[:c.lastInstruction.Position]
-	c.lastInstruction = c.previousInstruction
+	last := c.scopes[c.scopeIndex].lastInstruction
+	previous := c.scopes[c.scopeIndex].previousInstruction
+
+	old := c.currentInstructions()
+	new := old[:last.Position]
+
+	c.scopes[c.scopeIndex].instructions = new
+	c.scopes[c.scopeIndex].lastInstruction = previous
+}
+
+// keepBlockValue leaves the value of the block just compiled on the stack:
+// that of its last expression statement, or null if it ends in a statement
+// that produces no value.
+func (c *Compiler) keepBlockValue() {
+	if c.lastInstructionIs(code.OpPop) {
+		c.removeLastPop()
+	} else if !c.lastInstructionIs(code.OpReturnValue) {
+		c.emit(code.OpNull)
+	}
+}
+
+func (c *Compiler) replaceLastPopWithReturn() {
+	lastPos := c.scopes[c.scopeIndex].lastInstruction.Position
+	c.replaceInstruction(lastPos, code.Make(code.OpReturnValue))
+
+	c.scopes[c.scopeIndex].lastInstruction.Opcode = code.OpReturnValue
+}
+
+func (c *Compiler) enterScope() {
+	scope := CompilationScope{
+		instructions:        code.Instructions{},
+		lastInstruction:     EmittedInstruction{},
+		previousInstruction: EmittedInstruction{},
+	}
+	c.scopes = append(c.scopes, scope)
+	c.scopeIndex++
+
+	c.symbolTable = NewEnclosedSymbolTable(c.symbolTable)
+}
+
+func (c *Compiler) leaveScope() code.Instructions {
+	instructions := c.currentInstructions()
+
+	c.scopes = c.scopes[:len(c.scopes)-1]
+	c.scopeIndex--
+
+	c.symbolTable = c.symbolTable.Outer
+
+	return instructions
 }
 
 func (c *Compiler) addConstant(obj object.Object) int {
@@ -277,12 +1016,14 @@ func (c *Compiler) addConstant(obj object.Object) int {
 
 func (c *Compiler) Bytecode() *Bytecode {
 	return &Bytecode{
-		Instructions: c.instructions,
+		Instructions: c.currentInstructions(),
 		Constants:    c.constants,
+		Lines:        c.scopes[c.scopeIndex].lines,
 	}
 }
 
 type Bytecode struct {
 	Instructions code.Instructions
 	Constants    []object.Object
+	Lines        code.LineTable
 }
@@ -1,38 +1,330 @@
 package compiler
 
+import "fmt"
+
 type SymbolScope string
 
 const (
-	GlobalScope SymbolScope = "GLOBAL"
+	GlobalScope   SymbolScope = "GLOBAL"
+	LocalScope    SymbolScope = "LOCAL"
+	FreeScope     SymbolScope = "FREE"
+	FunctionScope SymbolScope = "FUNCTION"
+	ModuleScope   SymbolScope = "MODULE"
+	BuiltinScope  SymbolScope = "BUILTIN"
 )
 
 type Symbol struct {
-	Name  string
-	Scope SymbolScope
-	Index int
+	Name     string
+	Scope    SymbolScope
+	Index    int
+	Constant bool
 }
 
 type SymbolTable struct {
+	Outer *SymbolTable
+
 	store          map[string]Symbol
 	numDefinitions int
+
+	FreeSymbols []Symbol
+
+	// captured holds the names that closures created in this table, or
+	// in tables it encloses, refer to.
+	captured map[string]bool
+
+	// block is set for tables created by NewBlockSymbolTable.
+	block bool
+
+	// globals is set for tables created by NewModuleSymbolTable.
+	globals *SymbolTable
+	name    string
+	file    string
+	exports map[string]bool
+
+	// modules holds every module compiled against this global table,
+	// indexed by the ModuleScope symbols that refer to them.
+	modules []*SymbolTable
+
+	// externs is set for tables created by NewUnitSymbolTable.
+	externs map[string]int
 }
 
 func NewSymbolTable() *SymbolTable {
 	s := make(map[string]Symbol)
+	free := []Symbol{}
 	return &SymbolTable{
-		store: s,
+		store:       s,
+		FreeSymbols: free,
 	}
 }
 
+func NewEnclosedSymbolTable(outer *SymbolTable) *SymbolTable {
+	s := NewSymbolTable()
+	s.Outer = outer
+	return s
+}
+
+// NewBlockSymbolTable returns a table for a block statement nested in
+// outer. Names defined in it are only visible inside the block, but their
+// slots are allocated in the enclosing function (or global) table, so a
+// block does not need a frame of its own.
+func NewBlockSymbolTable(outer *SymbolTable) *SymbolTable {
+	s := NewEnclosedSymbolTable(outer)
+	s.block = true
+	return s
+}
+
+// NewModuleSymbolTable returns the global table of the module in file,
+// imported as name. Its names are only visible inside the module, but
+// their slots are allocated in globals, so all modules share one global
+// store.
+func NewModuleSymbolTable(globals *SymbolTable, name, file string) *SymbolTable {
+	s := NewSymbolTable()
+	s.globals = globals
+	s.name = name
+	s.file = file
+	s.exports = make(map[string]bool)
+
+	for name, symbol := range globals.store {
+		if symbol.Scope == BuiltinScope {
+			s.store[name] = symbol
+		}
+	}
+	return s
+}
+
+// NewUnitSymbolTable returns a global table for a separately compiled
+// unit. Names it cannot resolve become externs: they are given a global
+// slot of their own, which a linker later points at the definition
+// exported by another unit.
+func NewUnitSymbolTable() *SymbolTable {
+	s := NewSymbolTable()
+	s.externs = make(map[string]int)
+	return s
+}
+
+// Externs returns the slot reserved for each extern of a unit's table.
+func (st *SymbolTable) Externs() map[string]int {
+	return st.externs
+}
+
+// NumDefinitions returns the number of slots allocated in the table.
+func (st *SymbolTable) NumDefinitions() int {
+	return st.numDefinitions
+}
+
 func (st *SymbolTable) Define(name string) Symbol {
+	return st.define(name, false)
+}
+
+// DefineConstant defines a symbol that cannot be assigned to or redeclared
+// in this table.
+func (st *SymbolTable) DefineConstant(name string) Symbol {
+	return st.define(name, true)
+}
+
+func (st *SymbolTable) define(name string, constant bool) Symbol {
+	owner := st
+	for owner.block {
+		owner = owner.Outer
+	}
+
+	scope := LocalScope
+	if owner.Outer == nil {
+		scope = GlobalScope
+		owner = owner.globalTable()
+	}
+
+	// Redeclaring a name of this table rebinds the same variable, as in the
+	// evaluator, so closures that refer to it see the new value. Externs
+	// keep their slot for the linker.
+	if symbol, ok := st.store[name]; ok && symbol.Scope == scope {
+		if _, extern := st.externs[name]; !extern {
+			symbol.Constant = constant
+			st.store[name] = symbol
+			return symbol
+		}
+	}
+
+	symbol := Symbol{Name: name, Scope: scope, Index: owner.numDefinitions, Constant: constant}
 
-	symbol := Symbol{Name: name, Scope: GlobalScope, Index: st.numDefinitions}
 	st.store[name] = symbol
-	st.numDefinitions++
+	owner.numDefinitions++
+	return symbol
+}
+
+// DefineModule binds alias to the module at index in the global table's
+// list of modules.
+func (st *SymbolTable) DefineModule(alias string, index int) Symbol {
+	symbol := Symbol{Name: alias, Scope: ModuleScope, Index: index, Constant: true}
+	st.store[alias] = symbol
 	return symbol
 }
 
+// globalTable returns the table that owns the global slots and the list of
+// compiled modules.
+func (st *SymbolTable) globalTable() *SymbolTable {
+	t := st
+	for t.Outer != nil {
+		t = t.Outer
+	}
+
+	if t.globals != nil {
+		return t.globals
+	}
+	return t
+}
+
+// LookupModule returns the index of the module compiled from file, if any.
+func (st *SymbolTable) LookupModule(file string) (int, bool) {
+	for i, mod := range st.globalTable().modules {
+		if mod.file == file {
+			return i, true
+		}
+	}
+	return 0, false
+}
+
+// AddModule records mod, whose program exports the given names, and
+// returns its index.
+func (st *SymbolTable) AddModule(mod *SymbolTable, exports []string) int {
+	for _, name := range exports {
+		mod.exports[name] = true
+	}
+
+	globals := st.globalTable()
+	globals.modules = append(globals.modules, mod)
+	return len(globals.modules) - 1
+}
+
+// ResolveExport resolves name in the module referred to by the ModuleScope
+// symbol m.
+func (st *SymbolTable) ResolveExport(m Symbol, name string) (Symbol, error) {
+	mod := st.globalTable().modules[m.Index]
+
+	symbol, ok := mod.store[name]
+	if !ok || !mod.exports[name] {
+		return symbol, fmt.Errorf("module %s has no export %s", mod.name, name)
+	}
+	return symbol, nil
+}
+
+func (st *SymbolTable) DefineBuiltin(index int, name string) Symbol {
+	symbol := Symbol{Name: name, Index: index, Scope: BuiltinScope}
+	st.store[name] = symbol
+	return symbol
+}
+
+func (st *SymbolTable) DefineFunctionName(name string) Symbol {
+	symbol := Symbol{Name: name, Index: 0, Scope: FunctionScope}
+	st.store[name] = symbol
+	return symbol
+}
+
+func (st *SymbolTable) defineFree(original Symbol) Symbol {
+	st.FreeSymbols = append(st.FreeSymbols, original)
+
+	symbol := Symbol{Name: original.Name, Index: len(st.FreeSymbols) - 1}
+	symbol.Scope = FreeScope
+	symbol.Constant = original.Constant
+
+	st.store[original.Name] = symbol
+	return symbol
+}
+
+// hide removes the given names from the table, so that they resolve as if
+// they had not been defined yet, and returns their symbols for unhide.
+func (st *SymbolTable) hide(names []string) map[string]Symbol {
+	hidden := map[string]Symbol{}
+	for _, name := range names {
+		if symbol, ok := st.store[name]; ok {
+			hidden[name] = symbol
+			delete(st.store, name)
+		}
+	}
+	return hidden
+}
+
+// unhide restores the symbols removed by hide.
+func (st *SymbolTable) unhide(hidden map[string]Symbol) {
+	for name, symbol := range hidden {
+		st.store[name] = symbol
+	}
+}
+
+// IsCaptured reports whether a closure refers to name through this table,
+// so that redeclaring it here would not be seen by the closure. Only a
+// global of this table itself can be redeclared, since it keeps its slot.
+func (st *SymbolTable) IsCaptured(name string) bool {
+	if !st.captured[name] {
+		return false
+	}
+	symbol, ok := st.store[name]
+	return !ok || symbol.Scope != GlobalScope
+}
+
+// isCaptured reports whether symbol, resolved in st, is a local that a
+// closure has captured.
+func (st *SymbolTable) isCaptured(symbol Symbol) bool {
+	if symbol.Scope != LocalScope {
+		return false
+	}
+
+	for t := st; ; t = t.Outer {
+		if _, ok := t.store[symbol.Name]; ok {
+			return t.captured[symbol.Name]
+		}
+	}
+}
+
+// capture records that a closure created in st refers to name, in st and
+// in every table up to the one defining it.
+func (st *SymbolTable) capture(name string) {
+	for t := st; ; t = t.Outer {
+		if t.captured == nil {
+			t.captured = make(map[string]bool)
+		}
+		t.captured[name] = true
+
+		if _, ok := t.store[name]; ok {
+			return
+		}
+	}
+}
+
+// IsConstant reports whether name is a constant defined in this table
+// itself, not in an outer one.
+func (st *SymbolTable) IsConstant(name string) bool {
+	symbol, ok := st.store[name]
+	return ok && symbol.Constant && symbol.Scope != FreeScope
+}
+
 func (st *SymbolTable) Resolve(name string) (Symbol, bool) {
 	obj, ok := st.store[name]
+	if !ok && st.externs != nil {
+		obj = st.Define(name)
+		st.externs[name] = obj.Index
+		return obj, true
+	}
+	if !ok && st.Outer != nil {
+		obj, ok = st.Outer.Resolve(name)
+		if !ok {
+			return obj, ok
+		}
+
+		if !st.block && obj.Scope != BuiltinScope {
+			st.Outer.capture(name)
+		}
+
+		// Blocks share their function's frame, so only crossing a
+		// function boundary turns a local into a free variable.
+		if st.block || obj.Scope == GlobalScope || obj.Scope == BuiltinScope ||
+			obj.Scope == ModuleScope {
+			return obj, ok
+		}
+
+		free := st.defineFree(obj)
+		return free, true
+	}
 	return obj, ok
 }
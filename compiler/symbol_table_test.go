@@ -24,6 +24,38 @@ func TestDefine(t *testing.T) {
 
 }
 
+func TestRedefine(t *testing.T) {
+	global := NewSymbolTable()
+	global.Define("a")
+	global.Define("b")
+	global.DefineBuiltin(0, "len")
+
+	local := NewEnclosedSymbolTable(global)
+	local.Define("c")
+
+	expected := []struct {
+		table *SymbolTable
+		name  string
+		want  Symbol
+	}{
+		{global, "a", Symbol{Name: "a", Scope: GlobalScope, Index: 0}},
+		{global, "len", Symbol{Name: "len", Scope: GlobalScope, Index: 2}},
+		{local, "c", Symbol{Name: "c", Scope: LocalScope, Index: 0}},
+		{local, "a", Symbol{Name: "a", Scope: LocalScope, Index: 1}},
+	}
+
+	for _, tt := range expected {
+		if got := tt.table.Define(tt.name); got != tt.want {
+			t.Errorf("expected %s=%+v, got=%+v", tt.name, tt.want, got)
+		}
+	}
+
+	if global.numDefinitions != 3 || local.numDefinitions != 2 {
+		t.Errorf("wrong number of slots. got=%d and %d, want=3 and 2",
+			global.numDefinitions, local.numDefinitions)
+	}
+}
+
 func TestResolveGlobal(t *testing.T) {
 	global := NewSymbolTable()
 
@@ -51,3 +83,266 @@ func TestResolveGlobal(t *testing.T) {
 	}
 
 }
+
+func TestResolveLocal(t *testing.T) {
+	global := NewSymbolTable()
+	global.Define("a")
+
+	local := NewEnclosedSymbolTable(global)
+	local.Define("b")
+
+	expected := []Symbol{
+		{Name: "a", Scope: GlobalScope, Index: 0},
+		{Name: "b", Scope: LocalScope, Index: 0},
+	}
+
+	for _, sym := range expected {
+		result, ok := local.Resolve(sym.Name)
+		if !ok {
+			t.Errorf("name %s not resolvable", sym.Name)
+			continue
+		}
+		if result != sym {
+			t.Errorf("expected %s to resolve to %+v, got=%+v", sym.Name, sym, result)
+		}
+	}
+}
+
+func TestResolveFree(t *testing.T) {
+	global := NewSymbolTable()
+	global.Define("a")
+
+	firstLocal := NewEnclosedSymbolTable(global)
+	firstLocal.Define("b")
+
+	secondLocal := NewEnclosedSymbolTable(firstLocal)
+	secondLocal.Define("c")
+
+	expected := []Symbol{
+		{Name: "a", Scope: GlobalScope, Index: 0},
+		{Name: "b", Scope: FreeScope, Index: 0},
+		{Name: "c", Scope: LocalScope, Index: 0},
+	}
+
+	for _, sym := range expected {
+		result, ok := secondLocal.Resolve(sym.Name)
+		if !ok {
+			t.Errorf("name %s not resolvable", sym.Name)
+			continue
+		}
+		if result != sym {
+			t.Errorf("expected %s to resolve to %+v, got=%+v", sym.Name, sym, result)
+		}
+	}
+
+	if len(secondLocal.FreeSymbols) != 1 {
+		t.Fatalf("wrong number of free symbols. got=%d, want=1",
+			len(secondLocal.FreeSymbols))
+	}
+
+	want := Symbol{Name: "b", Scope: LocalScope, Index: 0}
+	if secondLocal.FreeSymbols[0] != want {
+		t.Errorf("wrong free symbol. got=%+v, want=%+v",
+			secondLocal.FreeSymbols[0], want)
+	}
+}
+
+func TestDefineAndResolveFunctionName(t *testing.T) {
+	global := NewSymbolTable()
+	global.DefineFunctionName("a")
+
+	expected := Symbol{Name: "a", Scope: FunctionScope, Index: 0}
+
+	result, ok := global.Resolve(expected.Name)
+	if !ok {
+		t.Fatalf("function name %s not resolvable", expected.Name)
+	}
+
+	if result != expected {
+		t.Errorf("expected %s to resolve to %+v, got=%+v",
+			expected.Name, expected, result)
+	}
+}
+
+func TestDefineConstant(t *testing.T) {
+	global := NewSymbolTable()
+	global.DefineConstant("a")
+
+	local := NewEnclosedSymbolTable(global)
+	local.DefineConstant("b")
+
+	inner := NewEnclosedSymbolTable(local)
+
+	expected := []Symbol{
+		{Name: "a", Scope: GlobalScope, Index: 0, Constant: true},
+		{Name: "b", Scope: FreeScope, Index: 0, Constant: true},
+	}
+
+	for _, sym := range expected {
+		result, ok := inner.Resolve(sym.Name)
+		if !ok {
+			t.Errorf("name %s not resolvable", sym.Name)
+			continue
+		}
+		if result != sym {
+			t.Errorf("expected %s to resolve to %+v, got=%+v", sym.Name, sym, result)
+		}
+	}
+
+	if !global.IsConstant("a") || !local.IsConstant("b") {
+		t.Errorf("constants not reported by the defining table")
+	}
+
+	if inner.IsConstant("b") {
+		t.Errorf("captured constant reported as defined in inner table")
+	}
+}
+
+func TestBlockSymbolTable(t *testing.T) {
+	global := NewSymbolTable()
+	global.Define("a")
+
+	block := NewBlockSymbolTable(global)
+	block.Define("a")
+
+	local := NewEnclosedSymbolTable(block)
+	local.Define("b")
+
+	inner := NewBlockSymbolTable(local)
+	inner.Define("c")
+
+	expected := []Symbol{
+		{Name: "a", Scope: GlobalScope, Index: 1},
+		{Name: "b", Scope: LocalScope, Index: 0},
+		{Name: "c", Scope: LocalScope, Index: 1},
+	}
+
+	for _, sym := range expected {
+		result, ok := inner.Resolve(sym.Name)
+		if !ok {
+			t.Errorf("name %s not resolvable", sym.Name)
+			continue
+		}
+		if result != sym {
+			t.Errorf("expected %s to resolve to %+v, got=%+v", sym.Name, sym, result)
+		}
+	}
+
+	if _, ok := local.Resolve("c"); ok {
+		t.Errorf("block symbol c resolvable outside its block")
+	}
+
+	if local.numDefinitions != 2 {
+		t.Errorf("wrong number of locals. got=%d, want=2", local.numDefinitions)
+	}
+
+	if len(local.FreeSymbols) != 0 || len(inner.FreeSymbols) != 0 {
+		t.Errorf("block lookups created free symbols")
+	}
+
+	a, _ := global.Resolve("a")
+	if a.Index != 0 {
+		t.Errorf("global a shadowed by block definition. got=%+v", a)
+	}
+}
+
+func TestModuleSymbolTable(t *testing.T) {
+	global := NewSymbolTable()
+	global.Define("a")
+
+	mod := NewModuleSymbolTable(global, "lib/m.mk", "/lib/m.mk")
+	a := mod.Define("a")
+	b := mod.Define("b")
+
+	if a != (Symbol{Name: "a", Scope: GlobalScope, Index: 1}) {
+		t.Errorf("module symbol a has wrong slot. got=%+v", a)
+	}
+	if b != (Symbol{Name: "b", Scope: GlobalScope, Index: 2}) {
+		t.Errorf("module symbol b has wrong slot. got=%+v", b)
+	}
+
+	if _, ok := global.Resolve("b"); ok {
+		t.Errorf("module symbol b resolvable in importer")
+	}
+
+	index := global.AddModule(mod, []string{"b"})
+	m := global.DefineModule("m", index)
+
+	local := NewEnclosedSymbolTable(global)
+	resolved, ok := local.Resolve("m")
+	if !ok || resolved != m || len(local.FreeSymbols) != 0 {
+		t.Errorf("module alias resolved wrongly. got=%+v", resolved)
+	}
+
+	if i, ok := local.LookupModule("/lib/m.mk"); !ok || i != index {
+		t.Errorf("module not cached. got=%d, %t", i, ok)
+	}
+
+	if export, err := local.ResolveExport(m, "b"); err != nil || export != b {
+		t.Errorf("wrong export b. got=%+v, err=%v", export, err)
+	}
+
+	_, err := local.ResolveExport(m, "a")
+	if err == nil || err.Error() != "module lib/m.mk has no export a" {
+		t.Errorf("wrong error for unexported a. got=%v", err)
+	}
+}
+
+func TestUnitSymbolTableExterns(t *testing.T) {
+	unit := NewUnitSymbolTable()
+	unit.Define("a")
+
+	local := NewEnclosedSymbolTable(unit)
+	x, ok := local.Resolve("x")
+	if !ok {
+		t.Fatalf("extern x not resolvable")
+	}
+
+	expected := Symbol{Name: "x", Scope: GlobalScope, Index: 1}
+	if x != expected {
+		t.Errorf("expected x to resolve to %+v, got=%+v", expected, x)
+	}
+
+	if len(unit.Externs()) != 1 || unit.Externs()["x"] != 1 {
+		t.Errorf("wrong externs. got=%v", unit.Externs())
+	}
+
+	if _, ok := NewSymbolTable().Resolve("x"); ok {
+		t.Errorf("plain global table resolved unknown name")
+	}
+}
+
+func TestDefineResolveBuiltins(t *testing.T) {
+	global := NewSymbolTable()
+	firstLocal := NewEnclosedSymbolTable(global)
+	secondLocal := NewEnclosedSymbolTable(firstLocal)
+
+	expected := []Symbol{
+		{Name: "a", Scope: BuiltinScope, Index: 0},
+		{Name: "c", Scope: BuiltinScope, Index: 1},
+		{Name: "e", Scope: BuiltinScope, Index: 2},
+		{Name: "f", Scope: BuiltinScope, Index: 3},
+	}
+
+	for i, v := range expected {
+		global.DefineBuiltin(i, v.Name)
+	}
+
+	for _, table := range []*SymbolTable{global, firstLocal, secondLocal} {
+		for _, sym := range expected {
+			result, ok := table.Resolve(sym.Name)
+			if !ok {
+				t.Errorf("name %s not resolvable", sym.Name)
+				continue
+			}
+			if result != sym {
+				t.Errorf("expected %s to resolve to %+v, got=%+v",
+					sym.Name, sym, result)
+			}
+		}
+	}
+
+	if len(secondLocal.FreeSymbols) != 0 {
+		t.Errorf("builtins were captured as free symbols. got=%+v", secondLocal.FreeSymbols)
+	}
+}
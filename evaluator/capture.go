@@ -0,0 +1,124 @@
+package evaluator
+
+import (
+	"monkey/ast"
+	"monkey/object"
+)
+
+// freeVariables caches the result of findFreeVariables, since a function
+// literal in a function body is evaluated on every call.
+var freeVariables = map[*ast.FunctionLiteral][]string{}
+
+// captureFreeVariables marks the variables that the closure created from
+// fn in env refers to as captured.
+func captureFreeVariables(fn *ast.FunctionLiteral, env *object.Environment) {
+	names, ok := freeVariables[fn]
+	if !ok {
+		names = findFreeVariables(fn)
+		freeVariables[fn] = names
+	}
+
+	for _, name := range names {
+		env.Capture(name)
+	}
+}
+
+// findFreeVariables returns the names fn refers to without binding them
+// itself, following the scoping of Eval: a let binds its name after its
+// value, a block has its own scope and a default cannot see its own or
+// later parameters.
+func findFreeVariables(fn *ast.FunctionLiteral) []string {
+	s := &scope{names: map[string]bool{}, free: &[]string{}}
+	s.function(fn)
+	return *s.free
+}
+
+// scope is an ast.Visitor that collects the free variables of the nodes
+// it visits.
+type scope struct {
+	names map[string]bool
+	outer *scope
+	free  *[]string
+}
+
+func (s *scope) enclosed() *scope {
+	return &scope{names: map[string]bool{}, outer: s, free: s.free}
+}
+
+func (s *scope) use(name string) {
+	for sc := s; sc != nil; sc = sc.outer {
+		if sc.names[name] {
+			return
+		}
+	}
+
+	for _, free := range *s.free {
+		if free == name {
+			return
+		}
+	}
+	*s.free = append(*s.free, name)
+}
+
+func (s *scope) function(fn *ast.FunctionLiteral) {
+	inner := s.enclosed()
+	if fn.Name != "" {
+		inner.names[fn.Name] = true
+	}
+
+	for _, p := range fn.Parameters {
+		if def, ok := fn.Defaults[p.Value]; ok {
+			ast.Walk(inner, def)
+		}
+		inner.names[p.Value] = true
+	}
+	if fn.Rest != nil {
+		inner.names[fn.Rest.Value] = true
+	}
+
+	ast.Walk(inner, fn.Body)
+}
+
+func (s *scope) Visit(node ast.Node) ast.Visitor {
+	switch node := node.(type) {
+	case *ast.Identifier:
+		s.use(node.Value)
+
+	case *ast.LetStatement:
+		if node.Value != nil {
+			ast.Walk(s, node.Value)
+		}
+		s.names[node.Name.Value] = true
+		return nil
+
+	case *ast.ImportStatement:
+		s.names[node.Alias.Value] = true
+		return nil
+
+	case *ast.BlockStatement:
+		return s.enclosed()
+
+	case *ast.TryStatement:
+		ast.Walk(s, node.Block)
+		if node.Catch != nil {
+			catch := s.enclosed()
+			if node.Param != nil {
+				catch.names[node.Param.Value] = true
+			}
+			ast.Walk(catch, node.Catch)
+		}
+		if node.Finally != nil {
+			ast.Walk(s, node.Finally)
+		}
+		return nil
+
+	case *ast.FunctionLiteral:
+		s.function(node)
+		return nil
+
+	case *ast.MacroLiteral:
+		return nil
+	}
+
+	return s
+}
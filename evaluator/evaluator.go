@@ -1,15 +1,16 @@
 package evaluator
 
 import (
+	"bytes"
 	"fmt"
 	"monkey/ast"
 	"monkey/object"
 )
 
 var (
-	NULL  = &object.Null{}
-	TRUE  = &object.Boolean{Value: true}
-	FALSE = &object.Boolean{Value: false}
+	NULL  = object.NULL
+	TRUE  = object.TRUE
+	FALSE = object.FALSE
 )
 
 func Eval(node ast.Node, env *object.Environment) object.Object {
@@ -22,6 +23,24 @@ func Eval(node ast.Node, env *object.Environment) object.Object {
 	case *ast.BlockStatement:
 		return evalBlockStatement(node, env)
 
+	case *ast.ImportStatement:
+		return evalImportStatement(node, env)
+
+	case *ast.ThrowStatement:
+		val := Eval(node.Value, env)
+		if isError(val) {
+			return val
+		}
+		thrown := object.ThrownError(val, node.Token.Line)
+		return &object.Error{
+			Message: object.ErrorMessage(thrown),
+			Line:    node.Token.Line,
+			Value:   thrown,
+		}
+
+	case *ast.TryStatement:
+		return evalTryStatement(node, env)
+
 	case *ast.ExpressionStatement:
 		return Eval(node.Expression, env)
 
@@ -37,7 +56,9 @@ func Eval(node ast.Node, env *object.Environment) object.Object {
 		if isError(val) {
 			return val
 		}
-		env.Set(node.Name.Value, val)
+		if err := env.Define(node.Name.Value, val, node.IsConst()); err != nil {
+			return newError("%s", err)
+		}
 
 	// Expressions
 	case *ast.IntegerLiteral:
@@ -46,9 +67,15 @@ func Eval(node ast.Node, env *object.Environment) object.Object {
 	case *ast.StringLiteral:
 		return &object.String{Value: node.Value}
 
+	case *ast.InterpolatedString:
+		return evalInterpolatedString(node, env)
+
 	case *ast.Boolean:
 		return nativeBoolToBooleanObject(node.Value)
 
+	case *ast.NullLiteral:
+		return NULL
+
 	case *ast.PrefixExpression:
 		right := Eval(node.Right, env)
 		if isError(right) {
@@ -62,6 +89,13 @@ func Eval(node ast.Node, env *object.Environment) object.Object {
 			return left
 		}
 
+		if node.Operator == "??" {
+			if left != NULL {
+				return left
+			}
+			return Eval(node.Right, env)
+		}
+
 		right := Eval(node.Right, env)
 		if isError(right) {
 			return right
@@ -78,20 +112,37 @@ func Eval(node ast.Node, env *object.Environment) object.Object {
 	case *ast.FunctionLiteral:
 		params := node.Parameters
 		body := node.Body
-		return &object.Function{Parameters: params, Env: env, Body: body}
+		captureFreeVariables(node, env)
+		return &object.Function{
+			Parameters: params,
+			Defaults:   node.Defaults,
+			Rest:       node.Rest,
+			Env:        env,
+			Body:       body,
+		}
 
 	case *ast.CallExpression:
-		function := Eval(node.Function, env)
-		if isError(function) {
-			return function
-		}
+		result, _ := evalCallExpression(node, env)
+		return result
 
-		args := evalExpressions(node.Arguments, env)
-		if len(args) == 1 && isError(args[0]) {
-			return args[0]
+	case *ast.AssignExpression:
+		val := Eval(node.Value, env)
+		if isError(val) {
+			return val
 		}
+		if err := env.Assign(node.Name.Value, val); err != nil {
+			if _, ok := env.Get(node.Name.Value); !ok && builtins[node.Name.Value] != nil {
+				return newError("cannot assign to builtin %s", node.Name.Value)
+			}
+			return newError("%s", err)
+		}
+		return val
+
+	case *ast.PipeExpression:
+		return Eval(node.Call(), env)
 
-		return applyFunction(function, args)
+	case *ast.MacroLiteral:
+		return newError("macros can only be defined by top-level let statements")
 
 	case *ast.ArrayLiteral:
 		elements := evalExpressions(node.Elements, env)
@@ -101,15 +152,8 @@ func Eval(node ast.Node, env *object.Environment) object.Object {
 		return &object.Array{Elements: elements}
 
 	case *ast.IndexExpression:
-		left := Eval(node.Left, env)
-		if isError(left) {
-			return left
-		}
-		index := Eval(node.Index, env)
-		if isError(index) {
-			return index
-		}
-		return evalIndexExpression(left, index)
+		result, _ := evalIndex(node, env)
+		return result
 
 	case *ast.HashLiteral:
 		return evalHashLiteral(node, env)
@@ -129,6 +173,7 @@ func evalProgram(program *ast.Program, env *object.Environment) object.Object {
 		case *object.ReturnValue:
 			return result.Value
 		case *object.Error:
+			locateError(result, statement)
 			return result
 		}
 	}
@@ -142,20 +187,84 @@ func evalBlockStatement(
 ) object.Object {
 	var result object.Object
 
+	// Each block gets its own scope, so a let inside it does not leak into
+	// the surrounding one.
+	env = object.NewEnclosedEnvironment(env)
+
 	for _, statement := range block.Statements {
 		result = Eval(statement, env)
 
 		if result != nil {
 			rt := result.Type()
+			if rt == object.ERROR_OBJ {
+				locateError(result.(*object.Error), statement)
+			}
 			if rt == object.RETURN_VALUE_OBJ || rt == object.ERROR_OBJ {
 				return result
 			}
 		}
 	}
 
+	if result == nil {
+		return NULL
+	}
+
 	return result
 }
 
+// locateError records the line of the innermost statement an error
+// passes through as the place it was raised.
+func locateError(err *object.Error, statement ast.Statement) {
+	if err.Line == 0 {
+		err.Line = ast.StatementLine(statement)
+	}
+}
+
+// evalTryStatement runs the catch block if the try block raises an error
+// and then the finally block in any case. A return or error from the
+// finally block replaces the outcome of the others.
+func evalTryStatement(node *ast.TryStatement, env *object.Environment) object.Object {
+	result := evalBlockStatement(node.Block, env)
+
+	if errObj, ok := result.(*object.Error); ok && node.Catch != nil {
+		catchEnv := object.NewEnclosedEnvironment(env)
+		if node.Param != nil {
+			catchEnv.Set(node.Param.Value, errorValue(errObj))
+		}
+		result = evalBlockStatement(node.Catch, catchEnv)
+	}
+
+	if node.Finally != nil {
+		finally := evalBlockStatement(node.Finally, env)
+		if isError(finally) || isReturnValue(finally) {
+			return finally
+		}
+	}
+
+	if isError(result) || isReturnValue(result) {
+		return result
+	}
+
+	return nil
+}
+
+// errorValue returns the error hash a catch clause binds for err.
+func errorValue(err *object.Error) *object.Hash {
+	if err.Value != nil {
+		return err.Value
+	}
+
+	kind := err.Kind
+	if kind == "" {
+		kind = object.RUNTIME_ERROR_KIND
+	}
+	return object.NewErrorHash(err.Message, kind, err.Line)
+}
+
+func isReturnValue(obj object.Object) bool {
+	return obj != nil && obj.Type() == object.RETURN_VALUE_OBJ
+}
+
 func nativeBoolToBooleanObject(input bool) *object.Boolean {
 	if input {
 		return TRUE
@@ -184,9 +293,9 @@ func evalInfixExpression(
 	case left.Type() == object.STRING_OBJ && right.Type() == object.STRING_OBJ:
 		return evalStringInfixExpression(operator, left, right)
 	case operator == "==":
-		return nativeBoolToBooleanObject(left == right)
+		return nativeBoolToBooleanObject(object.Equal(left, right))
 	case operator == "!=":
-		return nativeBoolToBooleanObject(left != right)
+		return nativeBoolToBooleanObject(!object.Equal(left, right))
 	case left.Type() != right.Type():
 		return newError("type mismatch: %s %s %s",
 			left.Type(), operator, right.Type())
@@ -252,14 +361,37 @@ func evalStringInfixExpression(
 	operator string,
 	left, right object.Object,
 ) object.Object {
-	if operator != "+" {
+	leftVal := left.(*object.String).Value
+	rightVal := right.(*object.String).Value
+
+	switch operator {
+	case "+":
+		return &object.String{Value: leftVal + rightVal}
+	case "==":
+		return nativeBoolToBooleanObject(leftVal == rightVal)
+	case "!=":
+		return nativeBoolToBooleanObject(leftVal != rightVal)
+	default:
 		return newError("unknown operator: %s %s %s",
 			left.Type(), operator, right.Type())
 	}
+}
 
-	leftVal := left.(*object.String).Value
-	rightVal := right.(*object.String).Value
-	return &object.String{Value: leftVal + rightVal}
+func evalInterpolatedString(
+	node *ast.InterpolatedString,
+	env *object.Environment,
+) object.Object {
+	var out bytes.Buffer
+
+	for _, part := range node.Parts {
+		value := Eval(part, env)
+		if isError(value) {
+			return value
+		}
+		out.WriteString(object.ToString(value))
+	}
+
+	return &object.String{Value: out.String()}
 }
 
 func evalIfExpression(
@@ -285,6 +417,9 @@ func evalIdentifier(
 	env *object.Environment,
 ) object.Object {
 	if val, ok := env.Get(node.Value); ok {
+		if _, ok := val.(*object.Module); ok {
+			return newError("module %s can only be used to access its exports", node.Value)
+		}
 		return val
 	}
 
@@ -326,6 +461,23 @@ func evalExpressions(
 	var result []object.Object
 
 	for _, e := range exps {
+		if spread, ok := e.(*ast.SpreadExpression); ok {
+			evaluated := Eval(spread.Value, env)
+			if isError(evaluated) {
+				return []object.Object{evaluated}
+			}
+
+			array, ok := evaluated.(*object.Array)
+			if !ok {
+				return []object.Object{
+					newError("spread operand must be ARRAY, got %s", evaluated.Type()),
+				}
+			}
+
+			result = append(result, array.Elements...)
+			continue
+		}
+
 		evaluated := Eval(e, env)
 		if isError(evaluated) {
 			return []object.Object{evaluated}
@@ -336,33 +488,81 @@ func evalExpressions(
 	return result
 }
 
-func applyFunction(fn object.Object, args []object.Object) object.Object {
+// applyFunction calls fn with args on behalf of code evaluated in caller.
+func applyFunction(fn object.Object, args []object.Object, caller *object.Environment) object.Object {
 	switch fn := fn.(type) {
 
 	case *object.Function:
-		extendedEnv := extendFunctionEnv(fn, args)
+		extendedEnv, err := extendFunctionEnv(fn, args)
+		if err != nil {
+			return err
+		}
 		evaluated := Eval(fn.Body, extendedEnv)
 		return unwrapReturnValue(evaluated)
 
 	case *object.Builtin:
-		return fn.Fn(args...)
+		if result := fn.Call(callContext(caller), args...); result != nil {
+			return result
+		}
+		return NULL
 
 	default:
 		return newError("not a function: %s", fn.Type())
 	}
 }
 
+// callContext returns the context of builtins called by code evaluated
+// in caller.
+func callContext(caller *object.Environment) *object.Context {
+	return &object.Context{
+		Apply: func(fn object.Object, args ...object.Object) object.Object {
+			return applyFunction(fn, args, caller)
+		},
+		Runtime: caller.Runtime(),
+	}
+}
+
+// extendFunctionEnv binds args to fn's parameters. Missing arguments take
+// their default values, which are evaluated left to right in the new
+// environment so they can refer to earlier parameters.
 func extendFunctionEnv(
 	fn *object.Function,
 	args []object.Object,
-) *object.Environment {
-	env := object.NewEnclosedEnvironment(fn.Env)
+) (*object.Environment, object.Object) {
+	required := len(fn.Parameters) - len(fn.Defaults)
+	max := len(fn.Parameters)
+	if fn.Rest != nil {
+		max = -1
+	}
+
+	if len(args) < required || (max >= 0 && len(args) > max) {
+		return nil, newError("%s", object.WrongArgumentCount(len(args), required, max))
+	}
+
+	env := object.NewFunctionEnvironment(fn.Env)
 
 	for paramIdx, param := range fn.Parameters {
-		env.Set(param.Value, args[paramIdx])
+		if paramIdx < len(args) {
+			env.Set(param.Value, args[paramIdx])
+			continue
+		}
+
+		value := Eval(fn.Defaults[param.Value], env)
+		if isError(value) {
+			return nil, value
+		}
+		env.Set(param.Value, value)
 	}
 
-	return env
+	if fn.Rest != nil {
+		rest := []object.Object{}
+		if len(args) > len(fn.Parameters) {
+			rest = append(rest, args[len(fn.Parameters):]...)
+		}
+		env.Set(fn.Rest.Value, &object.Array{Elements: rest})
+	}
+
+	return env, nil
 }
 
 func unwrapReturnValue(obj object.Object) object.Object {
@@ -373,6 +573,62 @@ func unwrapReturnValue(obj object.Object) object.Object {
 	return obj
 }
 
+// evalChainLeft evaluates the left side of a call or index expression.
+// It reports whether an optional index in the chain of calls and indexes
+// found null, in which case the rest of the chain is skipped and yields
+// null.
+func evalChainLeft(node ast.Expression, env *object.Environment) (object.Object, bool) {
+	switch node := node.(type) {
+	case *ast.CallExpression:
+		return evalCallExpression(node, env)
+	case *ast.IndexExpression:
+		return evalIndex(node, env)
+	default:
+		return Eval(node, env), false
+	}
+}
+
+func evalCallExpression(node *ast.CallExpression, env *object.Environment) (object.Object, bool) {
+	if node.Function.TokenLiteral() == "quote" {
+		if len(node.Arguments) != 1 {
+			return newError("quote: %s", object.WrongArgumentCount(len(node.Arguments), 1, 1)), false
+		}
+		return quote(node.Arguments[0], env), false
+	}
+
+	function, skipped := evalChainLeft(node.Function, env)
+	if skipped || isError(function) {
+		return function, skipped
+	}
+
+	args := evalExpressions(node.Arguments, env)
+	if len(args) == 1 && isError(args[0]) {
+		return args[0], false
+	}
+
+	return applyFunction(function, args, env), false
+}
+
+func evalIndex(node *ast.IndexExpression, env *object.Environment) (object.Object, bool) {
+	if mod, ok := moduleAlias(node.Left, env); ok {
+		return evalModuleMember(mod, node.Index), false
+	}
+
+	left, skipped := evalChainLeft(node.Left, env)
+	if skipped || isError(left) {
+		return left, skipped
+	}
+	if node.Optional && left == NULL {
+		return NULL, true
+	}
+
+	index := Eval(node.Index, env)
+	if isError(index) {
+		return index, false
+	}
+	return evalIndexExpression(left, index), false
+}
+
 func evalIndexExpression(left, index object.Object) object.Object {
 	switch {
 	case left.Type() == object.ARRAY_OBJ && index.Type() == object.INTEGER_OBJ:
@@ -400,43 +656,42 @@ func evalHashLiteral(
 	node *ast.HashLiteral,
 	env *object.Environment,
 ) object.Object {
-	pairs := make(map[object.HashKey]object.HashPair)
+	hash := object.NewHash()
 
-	for keyNode, valueNode := range node.Pairs {
-		key := Eval(keyNode, env)
+	for _, pair := range node.Pairs {
+		key := Eval(pair.Key, env)
 		if isError(key) {
 			return key
 		}
 
-		hashKey, ok := key.(object.Hashable)
+		hashKey, ok := object.AsHashable(key)
 		if !ok {
 			return newError("unusable as hash key: %s", key.Type())
 		}
 
-		value := Eval(valueNode, env)
+		value := Eval(pair.Value, env)
 		if isError(value) {
 			return value
 		}
 
-		hashed := hashKey.HashKey()
-		pairs[hashed] = object.HashPair{Key: key, Value: value}
+		hash.Set(hashKey, value)
 	}
 
-	return &object.Hash{Pairs: pairs}
+	return hash
 }
 
 func evalHashIndexExpression(hash, index object.Object) object.Object {
 	hashObject := hash.(*object.Hash)
 
-	key, ok := index.(object.Hashable)
+	key, ok := object.AsHashable(index)
 	if !ok {
 		return newError("unusable as hash key: %s", index.Type())
 	}
 
-	pair, ok := hashObject.Pairs[key.HashKey()]
+	value, ok := hashObject.Get(key)
 	if !ok {
 		return NULL
 	}
 
-	return pair.Value
+	return value
 }
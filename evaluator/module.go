@@ -0,0 +1,96 @@
+package evaluator
+
+import (
+	"monkey/ast"
+	"monkey/module"
+	"monkey/object"
+)
+
+// Loader resolves, parses and expands the files named by import
+// statements. It resolves imports made outside of any file against the
+// working directory until it is replaced.
+var Loader = module.NewLoader(".")
+
+func init() {
+	Loader.Expand = ExpandProgram
+}
+
+// modules caches every module by file, so each file is evaluated at most
+// once per process however often it is imported.
+var modules = map[string]*object.Module{}
+
+func evalImportStatement(node *ast.ImportStatement, env *object.Environment) object.Object {
+	mod := importModule(node.Path.Value, env.Runtime())
+	if isError(mod) {
+		return mod
+	}
+
+	if err := env.Define(node.Alias.Value, mod, true); err != nil {
+		return newError("%s", err)
+	}
+
+	return nil
+}
+
+// importModule returns the module in the file path names, evaluating it
+// with runtime on its first import.
+func importModule(path string, runtime *object.Runtime) object.Object {
+	file, err := Loader.Resolve(path)
+	if err != nil {
+		return newError("%s", err)
+	}
+
+	if mod, ok := modules[file]; ok {
+		return mod
+	}
+
+	if err := Loader.Enter(file); err != nil {
+		return newError("%s", err)
+	}
+	defer Loader.Leave()
+
+	program, err := Loader.Parse(file)
+	if err != nil {
+		return newError("%s", err)
+	}
+
+	env := object.NewEnvironment()
+	env.SetRuntime(runtime)
+	if result := Eval(program, env); isError(result) {
+		return result
+	}
+
+	mod := &object.Module{Name: path, Env: env, Exports: map[string]bool{}}
+	for _, name := range module.Exports(program) {
+		mod.Exports[name] = true
+	}
+
+	modules[file] = mod
+	return mod
+}
+
+// moduleAlias returns the module node names if it is a module alias.
+func moduleAlias(node ast.Expression, env *object.Environment) (*object.Module, bool) {
+	ident, ok := node.(*ast.Identifier)
+	if !ok {
+		return nil, false
+	}
+
+	val, _ := env.Get(ident.Value)
+	mod, ok := val.(*object.Module)
+	return mod, ok
+}
+
+func evalModuleMember(mod *object.Module, index ast.Expression) object.Object {
+	name, ok := index.(*ast.StringLiteral)
+	if !ok {
+		return newError("index operator not supported: %s", mod.Type())
+	}
+
+	val, ok := mod.Get(name.Value)
+	if !ok {
+		return newError("module %s has no export %s", mod.Name, name.Value)
+	}
+
+	return val
+}
@@ -0,0 +1,86 @@
+package evaluator
+
+import (
+	"fmt"
+	"monkey/ast"
+	"monkey/object"
+	"monkey/token"
+)
+
+// quote returns the quoted form of node. The unquote calls are replaced
+// in a copy, since node belongs to the function or macro being called.
+func quote(node ast.Node, env *object.Environment) object.Object {
+	node = evalUnquoteCalls(ast.Copy(node), env)
+	return &object.Quote{Node: node}
+}
+
+// evalUnquoteCalls replaces each unquote(x) call in quoted with the node
+// that produces the value of x. Calls whose value has no such node are
+// left alone.
+func evalUnquoteCalls(quoted ast.Node, env *object.Environment) ast.Node {
+	return ast.Modify(quoted, func(node ast.Node) ast.Node {
+		if !isUnquoteCall(node) {
+			return node
+		}
+
+		call, ok := node.(*ast.CallExpression)
+		if !ok {
+			return node
+		}
+
+		if len(call.Arguments) != 1 {
+			return node
+		}
+
+		unquoted := Eval(call.Arguments[0], env)
+		if converted := convertObjectToASTNode(unquoted); converted != nil {
+			return converted
+		}
+		return node
+	})
+}
+
+func isUnquoteCall(node ast.Node) bool {
+	callExpression, ok := node.(*ast.CallExpression)
+	if !ok {
+		return false
+	}
+
+	return callExpression.Function.TokenLiteral() == "unquote"
+}
+
+func convertObjectToASTNode(obj object.Object) ast.Node {
+	switch obj := obj.(type) {
+	case *object.Integer:
+		t := token.Token{
+			Type:    token.INT,
+			Literal: fmt.Sprintf("%d", obj.Value),
+		}
+		return &ast.IntegerLiteral{Token: t, Value: obj.Value}
+
+	case *object.Boolean:
+		var t token.Token
+		if obj.Value {
+			t = token.Token{Type: token.TRUE, Literal: "true"}
+		} else {
+			t = token.Token{Type: token.FALSE, Literal: "false"}
+		}
+		return &ast.Boolean{Token: t, Value: obj.Value}
+
+	case *object.String:
+		// The literal is source text, so it keeps the escapes of the repr.
+		repr := obj.Repr()
+		t := token.Token{Type: token.STRING, Literal: repr[1 : len(repr)-1]}
+		return &ast.StringLiteral{Token: t, Value: obj.Value}
+
+	case *object.Null:
+		t := token.Token{Type: token.NULL, Literal: "null"}
+		return &ast.NullLiteral{Token: t}
+
+	case *object.Quote:
+		return obj.Node
+
+	default:
+		return nil
+	}
+}
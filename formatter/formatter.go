@@ -29,9 +29,26 @@ func formatAstWithDepth(buf *bytes.Buffer, node ast.Node, depth int) {
 			formatAstWithDepth(buf, statement, depth+1)
 		}
 
+	case *ast.ImportStatement:
+		writeIndent(buf, depth)
+		buf.WriteString("IMPORT STATEMENT\n")
+		writeIndent(buf, depth+1)
+		buf.WriteString("(PATH)\n")
+		formatAstWithDepth(buf, node.Path, depth+2)
+		writeIndent(buf, depth+1)
+		buf.WriteString("(ALIAS)\n")
+		formatAstWithDepth(buf, node.Alias, depth+2)
+
 	case *ast.LetStatement:
 		writeIndent(buf, depth)
-		buf.WriteString("LET STATEMENT\n")
+		if node.Exported {
+			buf.WriteString("EXPORT ")
+		}
+		if node.IsConst() {
+			buf.WriteString("CONST STATEMENT\n")
+		} else {
+			buf.WriteString("LET STATEMENT\n")
+		}
 		writeIndent(buf, depth+1)
 		buf.WriteString("(NAME)\n")
 		formatAstWithDepth(buf, node.Name, depth+2)
@@ -60,6 +77,33 @@ func formatAstWithDepth(buf *bytes.Buffer, node ast.Node, depth int) {
 			formatAstWithDepth(buf, node.ReturnValue, depth+2)
 		}
 
+	case *ast.ThrowStatement:
+		writeIndent(buf, depth)
+		buf.WriteString("THROW STATEMENT\n")
+		writeIndent(buf, depth+1)
+		buf.WriteString("(VALUE)\n")
+		formatAstWithDepth(buf, node.Value, depth+2)
+
+	case *ast.TryStatement:
+		writeIndent(buf, depth)
+		buf.WriteString("TRY STATEMENT\n")
+		writeIndent(buf, depth+1)
+		buf.WriteString("BLOCK:\n")
+		formatAstWithDepth(buf, node.Block, depth+2)
+		if node.Catch != nil {
+			writeIndent(buf, depth+1)
+			buf.WriteString("CATCH:\n")
+			if node.Param != nil {
+				formatAstWithDepth(buf, node.Param, depth+2)
+			}
+			formatAstWithDepth(buf, node.Catch, depth+2)
+		}
+		if node.Finally != nil {
+			writeIndent(buf, depth+1)
+			buf.WriteString("FINALLY:\n")
+			formatAstWithDepth(buf, node.Finally, depth+2)
+		}
+
 	case *ast.ExpressionStatement:
 		writeIndent(buf, depth)
 		buf.WriteString("EXPRESSION STATEMENT\n")
@@ -78,6 +122,10 @@ func formatAstWithDepth(buf *bytes.Buffer, node ast.Node, depth int) {
 		buf.WriteString(strconv.FormatBool(node.Value))
 		buf.WriteRune('\n')
 
+	case *ast.NullLiteral:
+		writeIndent(buf, depth)
+		buf.WriteString("NULL\n")
+
 	case *ast.PrefixExpression:
 		writeIndent(buf, depth)
 		buf.WriteString("PREFIX EXPRESSION\n")
@@ -116,7 +164,33 @@ func formatAstWithDepth(buf *bytes.Buffer, node ast.Node, depth int) {
 
 	case *ast.FunctionLiteral:
 		writeIndent(buf, depth)
-		buf.WriteString("FUNCTION LITERAL\n")
+		if node.IsArrow() {
+			buf.WriteString("ARROW FUNCTION LITERAL\n")
+		} else {
+			buf.WriteString("FUNCTION LITERAL\n")
+		}
+		writeIndent(buf, depth+1)
+		buf.WriteString("PARAMETERS:\n")
+		for _, param := range node.Parameters {
+			formatAstWithDepth(buf, param, depth+2)
+			if def, ok := node.Defaults[param.Value]; ok {
+				writeIndent(buf, depth+3)
+				buf.WriteString("DEFAULT:\n")
+				formatAstWithDepth(buf, def, depth+4)
+			}
+		}
+		if node.Rest != nil {
+			writeIndent(buf, depth+1)
+			buf.WriteString("REST:\n")
+			formatAstWithDepth(buf, node.Rest, depth+2)
+		}
+		writeIndent(buf, depth+1)
+		buf.WriteString("BODY:\n")
+		formatAstWithDepth(buf, node.Body, depth+2)
+
+	case *ast.MacroLiteral:
+		writeIndent(buf, depth)
+		buf.WriteString("MACRO LITERAL\n")
 		writeIndent(buf, depth+1)
 		buf.WriteString("PARAMETERS:\n")
 		for _, param := range node.Parameters {
@@ -138,12 +212,41 @@ func formatAstWithDepth(buf *bytes.Buffer, node ast.Node, depth int) {
 			formatAstWithDepth(buf, arg, depth+2)
 		}
 
+	case *ast.AssignExpression:
+		writeIndent(buf, depth)
+		buf.WriteString("ASSIGN EXPRESSION\n")
+		writeIndent(buf, depth+1)
+		buf.WriteString("(NAME)\n")
+		formatAstWithDepth(buf, node.Name, depth+2)
+		writeIndent(buf, depth+1)
+		buf.WriteString("(VALUE)\n")
+		formatAstWithDepth(buf, node.Value, depth+2)
+
+	case *ast.PipeExpression:
+		writeIndent(buf, depth)
+		buf.WriteString("PIPE EXPRESSION\n")
+		writeIndent(buf, depth+1)
+		buf.WriteString("LEFT:\n")
+		formatAstWithDepth(buf, node.Left, depth+2)
+		writeIndent(buf, depth+1)
+		buf.WriteString("RIGHT:\n")
+		formatAstWithDepth(buf, node.Right, depth+2)
+
 	case *ast.StringLiteral:
 		writeIndent(buf, depth)
 		buf.WriteString("STRING: ")
 		buf.WriteString(node.Value)
 		buf.WriteRune('\n')
 
+	case *ast.InterpolatedString:
+		writeIndent(buf, depth)
+		buf.WriteString("INTERPOLATED STRING\n")
+		writeIndent(buf, depth+1)
+		buf.WriteString("PARTS:\n")
+		for _, part := range node.Parts {
+			formatAstWithDepth(buf, part, depth+2)
+		}
+
 	case *ast.ArrayLiteral:
 		writeIndent(buf, depth)
 		buf.WriteString("ARRAY LITERAL\n")
@@ -153,9 +256,20 @@ func formatAstWithDepth(buf *bytes.Buffer, node ast.Node, depth int) {
 			formatAstWithDepth(buf, element, depth+2)
 		}
 
+	case *ast.SpreadExpression:
+		writeIndent(buf, depth)
+		buf.WriteString("SPREAD EXPRESSION\n")
+		formatAstWithDepth(buf, node.Value, depth+1)
+
 	case *ast.IndexExpression:
 		writeIndent(buf, depth)
-		buf.WriteString("INDEX EXPRESSION\n")
+		if node.IsMember() {
+			buf.WriteString("MEMBER EXPRESSION\n")
+		} else if node.Optional {
+			buf.WriteString("OPTIONAL INDEX EXPRESSION\n")
+		} else {
+			buf.WriteString("INDEX EXPRESSION\n")
+		}
 		writeIndent(buf, depth+1)
 		buf.WriteString("LEFT:\n")
 		formatAstWithDepth(buf, node.Left, depth+2)
@@ -168,13 +282,13 @@ func formatAstWithDepth(buf *bytes.Buffer, node ast.Node, depth int) {
 		buf.WriteString("HASH LITERAL\n")
 		writeIndent(buf, depth+1)
 		buf.WriteString("PAIRS:\n")
-		for key, value := range node.Pairs {
+		for _, pair := range node.Pairs {
 			writeIndent(buf, depth+2)
 			buf.WriteString("KEY:\n")
-			formatAstWithDepth(buf, key, depth+3)
+			formatAstWithDepth(buf, pair.Key, depth+3)
 			writeIndent(buf, depth+2)
 			buf.WriteString("VALUE:\n")
-			formatAstWithDepth(buf, value, depth+3)
+			formatAstWithDepth(buf, pair.Value, depth+3)
 		}
 	}
 }
@@ -117,6 +117,150 @@ func TestFormatAST(t *testing.T) {
 				IDENTIFIER: a
 			INDEX:
 				INTEGER: 1
+`,
+		},
+		{
+			input: `fn(x, y = 1, ...rest) { f(...rest) };`,
+			expected: `PROGRAM
+	EXPRESSION STATEMENT
+		FUNCTION LITERAL
+			PARAMETERS:
+				IDENTIFIER: x
+				IDENTIFIER: y
+					DEFAULT:
+						INTEGER: 1
+			REST:
+				IDENTIFIER: rest
+			BODY:
+				BLOCK STATEMENT
+					EXPRESSION STATEMENT
+						CALL EXPRESSION
+							FUNCTION:
+								IDENTIFIER: f
+							ARGUMENTS:
+								SPREAD EXPRESSION
+									IDENTIFIER: rest
+`,
+		},
+		{
+			input: `"Hello ${name}!"`,
+			expected: `PROGRAM
+	EXPRESSION STATEMENT
+		INTERPOLATED STRING
+			PARTS:
+				STRING: Hello 
+				IDENTIFIER: name
+				STRING: !
+`,
+		},
+		{
+			input: `xs |> map(x => x * 2);`,
+			expected: `PROGRAM
+	EXPRESSION STATEMENT
+		PIPE EXPRESSION
+			LEFT:
+				IDENTIFIER: xs
+			RIGHT:
+				CALL EXPRESSION
+					FUNCTION:
+						IDENTIFIER: map
+					ARGUMENTS:
+						ARROW FUNCTION LITERAL
+							PARAMETERS:
+								IDENTIFIER: x
+							BODY:
+								BLOCK STATEMENT
+									EXPRESSION STATEMENT
+										INFIX EXPRESSION
+											OPERATOR: *
+											LEFT:
+												IDENTIFIER: x
+											RIGHT:
+												INTEGER: 2
+`,
+		},
+		{
+			input: `a?[0] ?? null;`,
+			expected: `PROGRAM
+	EXPRESSION STATEMENT
+		INFIX EXPRESSION
+			OPERATOR: ??
+			LEFT:
+				OPTIONAL INDEX EXPRESSION
+					LEFT:
+						IDENTIFIER: a
+					INDEX:
+						INTEGER: 0
+			RIGHT:
+				NULL
+`,
+		},
+		{
+			input: `const x = 1; x = 2;`,
+			expected: `PROGRAM
+	CONST STATEMENT
+		(NAME)
+			IDENTIFIER: x
+		(VALUE)
+			INTEGER: 1
+	EXPRESSION STATEMENT
+		ASSIGN EXPRESSION
+			(NAME)
+				IDENTIFIER: x
+			(VALUE)
+				INTEGER: 2
+`,
+		},
+		{
+			input: `import "lib/x.mk" as x; export let y = x.z;`,
+			expected: `PROGRAM
+	IMPORT STATEMENT
+		(PATH)
+			STRING: lib/x.mk
+		(ALIAS)
+			IDENTIFIER: x
+	EXPORT LET STATEMENT
+		(NAME)
+			IDENTIFIER: y
+		(VALUE)
+			MEMBER EXPRESSION
+				LEFT:
+					IDENTIFIER: x
+				INDEX:
+					STRING: z
+`,
+		},
+		{
+			input: `try { throw 1; } catch (e) { e } finally { 2 }`,
+			expected: `PROGRAM
+	TRY STATEMENT
+		BLOCK:
+			BLOCK STATEMENT
+				THROW STATEMENT
+					(VALUE)
+						INTEGER: 1
+		CATCH:
+			IDENTIFIER: e
+			BLOCK STATEMENT
+				EXPRESSION STATEMENT
+					IDENTIFIER: e
+		FINALLY:
+			BLOCK STATEMENT
+				EXPRESSION STATEMENT
+					INTEGER: 2
+`,
+		},
+		{
+			input: `macro(x) { x };`,
+			expected: `PROGRAM
+	EXPRESSION STATEMENT
+		MACRO LITERAL
+			PARAMETERS:
+				IDENTIFIER: x
+			BODY:
+				BLOCK STATEMENT
+					EXPRESSION STATEMENT
+						IDENTIFIER: x
 `,
 		},
 	}
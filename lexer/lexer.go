@@ -7,10 +7,11 @@ type Lexer struct {
 	position     int  // current position in input (points to current char)
 	readPosition int  // current reading position in input (after current char)
 	ch           byte // current char under examination
+	line         int  // line of the current char, starting at 1
 }
 
 func New(input string) *Lexer {
-	l := &Lexer{input: input}
+	l := &Lexer{input: input, line: 1}
 	l.readChar()
 	return l
 }
@@ -20,6 +21,8 @@ func (l *Lexer) NextToken() token.Token {
 
 	l.skipWhitespace()
 
+	line := l.line
+
 	switch l.ch {
 	case '=':
 		if l.peekChar() == '=' {
@@ -27,6 +30,11 @@ func (l *Lexer) NextToken() token.Token {
 			l.readChar()
 			literal := string(ch) + string(l.ch)
 			tok = token.Token{Type: token.EQ, Literal: literal}
+		} else if l.peekChar() == '>' {
+			ch := l.ch
+			l.readChar()
+			literal := string(ch) + string(l.ch)
+			tok = token.Token{Type: token.ARROW, Literal: literal}
 		} else {
 			tok = newToken(token.ASSIGN, l.ch)
 		}
@@ -43,6 +51,29 @@ func (l *Lexer) NextToken() token.Token {
 		} else {
 			tok = newToken(token.BANG, l.ch)
 		}
+	case '|':
+		if l.peekChar() == '>' {
+			ch := l.ch
+			l.readChar()
+			literal := string(ch) + string(l.ch)
+			tok = token.Token{Type: token.PIPE, Literal: literal}
+		} else {
+			tok = newToken(token.ILLEGAL, l.ch)
+		}
+	case '?':
+		if l.peekChar() == '?' {
+			ch := l.ch
+			l.readChar()
+			literal := string(ch) + string(l.ch)
+			tok = token.Token{Type: token.NULLISH, Literal: literal}
+		} else if l.peekChar() == '[' {
+			ch := l.ch
+			l.readChar()
+			literal := string(ch) + string(l.ch)
+			tok = token.Token{Type: token.OPT_LBRACKET, Literal: literal}
+		} else {
+			tok = newToken(token.ILLEGAL, l.ch)
+		}
 	case '/':
 		tok = newToken(token.SLASH, l.ch)
 	case '*':
@@ -66,8 +97,23 @@ func (l *Lexer) NextToken() token.Token {
 	case ')':
 		tok = newToken(token.RPAREN, l.ch)
 	case '"':
+		literal, interpolated, terminated := l.readString()
 		tok.Type = token.STRING
-		tok.Literal = l.readString()
+		if interpolated {
+			tok.Type = token.INTERP_STRING
+		}
+		tok.Literal = literal
+		if !terminated {
+			tok = token.Token{Type: token.ILLEGAL, Literal: `"` + literal}
+		}
+	case '.':
+		if l.peekChar() == '.' && l.peekCharAt(1) == '.' {
+			l.readChar()
+			l.readChar()
+			tok = token.Token{Type: token.ELLIPSIS, Literal: "..."}
+		} else {
+			tok = newToken(token.DOT, l.ch)
+		}
 	case '[':
 		tok = newToken(token.LBRACKET, l.ch)
 	case ']':
@@ -79,10 +125,12 @@ func (l *Lexer) NextToken() token.Token {
 		if isLetter(l.ch) {
 			tok.Literal = l.readIdentifier()
 			tok.Type = token.LookupIdent(tok.Literal)
+			tok.Line = line
 			return tok
 		} else if isDigit(l.ch) {
 			tok.Type = token.INT
 			tok.Literal = l.readNumber()
+			tok.Line = line
 			return tok
 		} else {
 			tok = newToken(token.ILLEGAL, l.ch)
@@ -90,6 +138,7 @@ func (l *Lexer) NextToken() token.Token {
 	}
 
 	l.readChar()
+	tok.Line = line
 	return tok
 }
 
@@ -100,6 +149,9 @@ func (l *Lexer) skipWhitespace() {
 }
 
 func (l *Lexer) readChar() {
+	if l.ch == '\n' {
+		l.line++
+	}
 	if l.readPosition >= len(l.input) {
 		l.ch = 0
 	} else {
@@ -117,6 +169,14 @@ func (l *Lexer) peekChar() byte {
 	}
 }
 
+// peekCharAt looks n characters past the next one without consuming input.
+func (l *Lexer) peekCharAt(n int) byte {
+	if l.readPosition+n >= len(l.input) {
+		return 0
+	}
+	return l.input[l.readPosition+n]
+}
+
 func (l *Lexer) readIdentifier() string {
 	position := l.position
 	for isLetter(l.ch) {
@@ -133,15 +193,57 @@ func (l *Lexer) readNumber() string {
 	return l.input[position:l.position]
 }
 
-func (l *Lexer) readString() string {
+// readString reads up to the closing quote and reports whether the string
+// contains ${...} placeholders and whether the quote was found before the
+// end of the input. Quotes inside a placeholder belong to the embedded
+// expression and do not end the string.
+func (l *Lexer) readString() (string, bool, bool) {
 	position := l.position + 1
+	interpolated := false
 	for {
 		l.readChar()
+		if l.ch == '\\' {
+			// The escaped character can neither end the string nor
+			// start a placeholder; the parser resolves the escape.
+			if l.readChar(); l.ch == 0 {
+				break
+			}
+			continue
+		}
+		if l.ch == '$' && l.peekChar() == '{' {
+			interpolated = true
+			l.readChar()
+			l.skipPlaceholder()
+			if l.ch == 0 {
+				break
+			}
+			continue
+		}
 		if l.ch == '"' || l.ch == 0 {
 			break
 		}
 	}
-	return l.input[position:l.position]
+	return l.input[position:l.position], interpolated, l.ch == '"'
+}
+
+// skipPlaceholder advances to the brace that closes a ${ placeholder.
+func (l *Lexer) skipPlaceholder() {
+	depth := 1
+	for depth > 0 {
+		l.readChar()
+		switch l.ch {
+		case '{':
+			depth++
+		case '}':
+			depth--
+		case '"':
+			if l.readString(); l.ch == 0 {
+				return
+			}
+		case 0:
+			return
+		}
+	}
 }
 
 func isLetter(ch byte) bool {
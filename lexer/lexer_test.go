@@ -30,6 +30,13 @@ if (5 < 10) {
 "foo bar"
 [1, 2];
 {"foo": "bar"}
+f(...args);
+"Hello ${name}, ${ {"a": "}"}["a"] }!"
+xs |> map(x => x * 2);
+a?["k"] ?? null;
+import "lib/x.mk" as x; export const y = x.z;
+try { throw e; } catch (e) {} finally {}
+macro(x, y) { x + y; };
 `
 
 	tests := []struct {
@@ -122,6 +129,72 @@ if (5 < 10) {
 		{token.COLON, ":"},
 		{token.STRING, "bar"},
 		{token.RBRACE, "}"},
+		{token.IDENT, "f"},
+		{token.LPAREN, "("},
+		{token.ELLIPSIS, "..."},
+		{token.IDENT, "args"},
+		{token.RPAREN, ")"},
+		{token.SEMICOLON, ";"},
+		{token.INTERP_STRING, `Hello ${name}, ${ {"a": "}"}["a"] }!`},
+		{token.IDENT, "xs"},
+		{token.PIPE, "|>"},
+		{token.IDENT, "map"},
+		{token.LPAREN, "("},
+		{token.IDENT, "x"},
+		{token.ARROW, "=>"},
+		{token.IDENT, "x"},
+		{token.ASTERISK, "*"},
+		{token.INT, "2"},
+		{token.RPAREN, ")"},
+		{token.SEMICOLON, ";"},
+		{token.IDENT, "a"},
+		{token.OPT_LBRACKET, "?["},
+		{token.STRING, "k"},
+		{token.RBRACKET, "]"},
+		{token.NULLISH, "??"},
+		{token.NULL, "null"},
+		{token.SEMICOLON, ";"},
+		{token.IMPORT, "import"},
+		{token.STRING, "lib/x.mk"},
+		{token.AS, "as"},
+		{token.IDENT, "x"},
+		{token.SEMICOLON, ";"},
+		{token.EXPORT, "export"},
+		{token.CONST, "const"},
+		{token.IDENT, "y"},
+		{token.ASSIGN, "="},
+		{token.IDENT, "x"},
+		{token.DOT, "."},
+		{token.IDENT, "z"},
+		{token.SEMICOLON, ";"},
+		{token.TRY, "try"},
+		{token.LBRACE, "{"},
+		{token.THROW, "throw"},
+		{token.IDENT, "e"},
+		{token.SEMICOLON, ";"},
+		{token.RBRACE, "}"},
+		{token.CATCH, "catch"},
+		{token.LPAREN, "("},
+		{token.IDENT, "e"},
+		{token.RPAREN, ")"},
+		{token.LBRACE, "{"},
+		{token.RBRACE, "}"},
+		{token.FINALLY, "finally"},
+		{token.LBRACE, "{"},
+		{token.RBRACE, "}"},
+		{token.MACRO, "macro"},
+		{token.LPAREN, "("},
+		{token.IDENT, "x"},
+		{token.COMMA, ","},
+		{token.IDENT, "y"},
+		{token.RPAREN, ")"},
+		{token.LBRACE, "{"},
+		{token.IDENT, "x"},
+		{token.PLUS, "+"},
+		{token.IDENT, "y"},
+		{token.SEMICOLON, ";"},
+		{token.RBRACE, "}"},
+		{token.SEMICOLON, ";"},
 		{token.EOF, ""},
 	}
 
@@ -141,3 +214,103 @@ if (5 < 10) {
 		}
 	}
 }
+
+func TestStringEscapes(t *testing.T) {
+	input := `"say \"hi\"" "\${x} ${y}" "a\\" "abc\`
+
+	tests := []struct {
+		expectedType    token.TokenType
+		expectedLiteral string
+	}{
+		{token.STRING, `say \"hi\"`},
+		{token.INTERP_STRING, `\${x} ${y}`},
+		{token.STRING, `a\\`},
+		{token.ILLEGAL, `"abc\`},
+		{token.EOF, ""},
+	}
+
+	l := New(input)
+
+	for i, tt := range tests {
+		tok := l.NextToken()
+
+		if tok.Type != tt.expectedType {
+			t.Fatalf("tests[%d] - tokentype wrong. expected=%q, got=%q",
+				i, tt.expectedType, tok.Type)
+		}
+
+		if tok.Literal != tt.expectedLiteral {
+			t.Fatalf("tests[%d] - literal wrong. expected=%q, got=%q",
+				i, tt.expectedLiteral, tok.Literal)
+		}
+	}
+}
+
+func TestUnterminatedStrings(t *testing.T) {
+	tests := []struct {
+		input           string
+		expectedLiteral string
+	}{
+		{`"abc`, `"abc`},
+		{`"x ${`, `"x ${`},
+		{`"x ${ "a`, `"x ${ "a`},
+		{`"x ${ {`, `"x ${ {`},
+		{`"x ${y} z`, `"x ${y} z`},
+	}
+
+	for _, tt := range tests {
+		l := New(tt.input)
+
+		tok := l.NextToken()
+		if tok.Type != token.ILLEGAL {
+			t.Fatalf("%s - tokentype wrong. expected=%q, got=%q",
+				tt.input, token.ILLEGAL, tok.Type)
+		}
+		if tok.Literal != tt.expectedLiteral {
+			t.Fatalf("%s - literal wrong. expected=%q, got=%q",
+				tt.input, tt.expectedLiteral, tok.Literal)
+		}
+
+		if tok := l.NextToken(); tok.Type != token.EOF {
+			t.Fatalf("%s - expected EOF, got=%q", tt.input, tok.Type)
+		}
+	}
+}
+
+func TestTokenLines(t *testing.T) {
+	input := "let a = 1;\n\nlet b = \"x\ny\";\nb"
+
+	tests := []struct {
+		expectedLiteral string
+		expectedLine    int
+	}{
+		{"let", 1},
+		{"a", 1},
+		{"=", 1},
+		{"1", 1},
+		{";", 1},
+		{"let", 3},
+		{"b", 3},
+		{"=", 3},
+		{"x\ny", 3},
+		{";", 4},
+		{"b", 5},
+		{"", 5},
+	}
+
+	l := New(input)
+
+	for i, tt := range tests {
+		tok := l.NextToken()
+
+		if tok.Literal != tt.expectedLiteral {
+			t.Fatalf("tests[%d] - literal wrong. expected=%q, got=%q",
+				i, tt.expectedLiteral, tok.Literal)
+		}
+
+		if tok.Line != tt.expectedLine {
+			t.Fatalf("tests[%d] - line wrong. expected=%d, got=%d",
+				i, tt.expectedLine, tok.Line)
+		}
+	}
+}
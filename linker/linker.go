@@ -0,0 +1,213 @@
+// Package linker compiles modules into separate bytecode units and links
+// units into a single compiler.Bytecode.
+package linker
+
+import (
+	"fmt"
+	"monkey/ast"
+	"monkey/code"
+	"monkey/compiler"
+	"monkey/module"
+	"monkey/object"
+	"sort"
+)
+
+// Unit is the bytecode of one separately compiled module. Its constant
+// and global indices start at zero and are relocated when it is linked.
+type Unit struct {
+	Name         string
+	Instructions code.Instructions
+	Constants    []object.Object
+	Lines        code.LineTable
+	NumGlobals   int
+
+	// Exports maps each exported name to its global slot in the unit.
+	Exports map[string]int
+	// Externs maps each name the unit uses without defining to the global
+	// slot reserved for it, which linking points at the exported
+	// definition of another unit.
+	Externs map[string]int
+}
+
+// Compile compiles program into a unit called name.
+func Compile(name string, program *ast.Program) (*Unit, error) {
+	symbolTable := compiler.NewUnitSymbolTable()
+	for i, v := range object.Builtins {
+		symbolTable.DefineBuiltin(i, v.Name)
+	}
+
+	comp := compiler.NewWithState(symbolTable, []object.Object{})
+	if err := comp.Compile(program); err != nil {
+		return nil, fmt.Errorf("%s: %s", name, err)
+	}
+	bytecode := comp.Bytecode()
+
+	unit := &Unit{
+		Name:         name,
+		Instructions: bytecode.Instructions,
+		Constants:    bytecode.Constants,
+		Lines:        bytecode.Lines,
+		NumGlobals:   symbolTable.NumDefinitions(),
+		Exports:      map[string]int{},
+		Externs:      map[string]int{},
+	}
+
+	for _, export := range module.Exports(program) {
+		symbol, _ := symbolTable.Resolve(export)
+		unit.Exports[export] = symbol.Index
+	}
+
+	// A name used before the unit defines it, as in mutually recursive
+	// functions, was made an extern; point it at the definition instead.
+	forward := map[int]int{}
+	for extern, slot := range symbolTable.Externs() {
+		symbol, _ := symbolTable.Resolve(extern)
+		if symbol.Index != slot {
+			forward[slot] = symbol.Index
+		} else {
+			unit.Externs[extern] = slot
+		}
+	}
+
+	if len(forward) > 0 {
+		r := &relocation{globals: func(slot int) int {
+			if to, ok := forward[slot]; ok {
+				return to
+			}
+			return slot
+		}}
+		unit.Instructions = r.apply(unit.Instructions, 0)
+		unit.Constants = r.constants(unit.Constants)
+	}
+
+	return unit, nil
+}
+
+// Link combines units into one program that runs their instructions in
+// the given order. It fails when two units export the same name or when
+// an extern is not exported by any unit.
+func Link(units ...*Unit) (*compiler.Bytecode, error) {
+	bases := make([]int, len(units))
+	exports := map[string]int{}
+	definedIn := map[string]string{}
+
+	numGlobals := 0
+	for i, u := range units {
+		bases[i] = numGlobals
+		numGlobals += u.NumGlobals
+
+		for _, name := range sortedNames(u.Exports) {
+			if other, ok := definedIn[name]; ok {
+				return nil, fmt.Errorf("duplicate symbol %s: exported by %s and %s",
+					name, other, u.Name)
+			}
+			definedIn[name] = u.Name
+			exports[name] = bases[i] + u.Exports[name]
+		}
+	}
+
+	bytecode := &compiler.Bytecode{
+		Instructions: code.Instructions{},
+		Constants:    []object.Object{},
+	}
+
+	for i, u := range units {
+		externs := map[int]int{}
+		for _, name := range sortedNames(u.Externs) {
+			slot, ok := exports[name]
+			if !ok {
+				return nil, fmt.Errorf("unresolved symbol %s in %s", name, u.Name)
+			}
+			externs[u.Externs[name]] = slot
+		}
+
+		base := bases[i]
+		r := &relocation{
+			constantBase: len(bytecode.Constants),
+			globals: func(slot int) int {
+				if to, ok := externs[slot]; ok {
+					return to
+				}
+				return base + slot
+			},
+		}
+
+		offset := len(bytecode.Instructions)
+		bytecode.Instructions = append(bytecode.Instructions, r.apply(u.Instructions, offset)...)
+		bytecode.Constants = append(bytecode.Constants, r.constants(u.Constants)...)
+
+		for _, entry := range u.Lines {
+			entry.Offset += offset
+			bytecode.Lines = append(bytecode.Lines, entry)
+		}
+	}
+
+	return bytecode, nil
+}
+
+// relocation rewrites the operands of instructions moved into a program
+// with other constants and globals before them.
+type relocation struct {
+	constantBase int
+	globals      func(slot int) int
+}
+
+// apply returns a relocated copy of ins, whose jumps are also moved by
+// offset bytes.
+func (r *relocation) apply(ins code.Instructions, offset int) code.Instructions {
+	out := make(code.Instructions, 0, len(ins))
+
+	for i := 0; i < len(ins); {
+		op := code.Opcode(ins[i])
+		def, err := code.Lookup(ins[i])
+		if err != nil {
+			panic(err)
+		}
+
+		operands, read := code.ReadOperands(def, ins[i+1:])
+
+		switch op {
+		case code.OpConstant, code.OpClosure:
+			operands[0] += r.constantBase
+		case code.OpGetGlobal, code.OpSetGlobal:
+			operands[0] = r.globals(operands[0])
+		case code.OpJump, code.OpJumpNotNotTruthy, code.OpJumpNull, code.OpSetupHandler:
+			operands[0] += offset
+		}
+
+		out = append(out, code.Make(op, operands...)...)
+		i += 1 + read
+	}
+
+	return out
+}
+
+// constants returns a copy of constants with the instructions of compiled
+// functions relocated. Jumps in function bodies are relative to the body
+// and stay as they are.
+func (r *relocation) constants(constants []object.Object) []object.Object {
+	out := make([]object.Object, len(constants))
+
+	for i, c := range constants {
+		fn, ok := c.(*object.CompiledFunction)
+		if !ok {
+			out[i] = c
+			continue
+		}
+
+		relocated := *fn
+		relocated.Instructions = r.apply(fn.Instructions, 0)
+		out[i] = &relocated
+	}
+
+	return out
+}
+
+func sortedNames(m map[string]int) []string {
+	names := make([]string, 0, len(m))
+	for name := range m {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
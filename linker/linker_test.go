@@ -0,0 +1,119 @@
+package linker
+
+import (
+	"monkey/ast"
+	"monkey/code"
+	"monkey/lexer"
+	"monkey/object"
+	"monkey/parser"
+	"monkey/vm"
+	"testing"
+)
+
+func parse(input string) *ast.Program {
+	l := lexer.New(input)
+	p := parser.New(l)
+	return p.ParseProgram()
+}
+
+func compileUnit(t *testing.T, name, input string) *Unit {
+	t.Helper()
+
+	unit, err := Compile(name, parse(input))
+	if err != nil {
+		t.Fatalf("compile error: %s", err)
+	}
+	return unit
+}
+
+func TestCompile(t *testing.T) {
+	unit := compileUnit(t, "a", `
+		let local = 1;
+		export let twice = fn(x) { helper(x) + helper(x) };
+		export let helper = fn(x) { x + base };
+	`)
+
+	if unit.NumGlobals != 5 {
+		t.Errorf("wrong number of globals. got=%d, want=5", unit.NumGlobals)
+	}
+
+	expectedExports := map[string]int{"twice": 2, "helper": 4}
+	if len(unit.Exports) != len(expectedExports) {
+		t.Fatalf("wrong exports. got=%v", unit.Exports)
+	}
+	for name, slot := range expectedExports {
+		if unit.Exports[name] != slot {
+			t.Errorf("export %s has wrong slot. got=%d, want=%d", name, unit.Exports[name], slot)
+		}
+	}
+
+	if len(unit.Externs) != 1 || unit.Externs["base"] != 3 {
+		t.Errorf("wrong externs. got=%v", unit.Externs)
+	}
+
+	twice := unit.Constants[1].(*object.CompiledFunction)
+	expected := code.Make(code.OpGetGlobal, 4)
+	if string(twice.Instructions[:len(expected)]) != string(expected) {
+		t.Errorf("forward reference not relocated.\n%s", twice.Instructions)
+	}
+}
+
+func TestLink(t *testing.T) {
+	lib := compileUnit(t, "lib", `
+		let offset = 100;
+		export let base = 10;
+		export let add = fn(x) { if (x > 0) { x + base } else { offset } };
+	`)
+	app := compileUnit(t, "app", `
+		let offset = 1;
+		let r = add(5) + add(0);
+		if (true) { r + offset } else { 0 };
+	`)
+
+	bytecode, err := Link(lib, app)
+	if err != nil {
+		t.Fatalf("link error: %s", err)
+	}
+
+	machine := vm.New(bytecode)
+	if err := machine.Run(); err != nil {
+		t.Fatalf("vm error: %s", err)
+	}
+
+	result, ok := machine.LastPoppedStackElem().(*object.Integer)
+	if !ok || result.Value != 116 {
+		t.Errorf("wrong result. got=%+v, want=116", machine.LastPoppedStackElem())
+	}
+}
+
+func TestLinkErrors(t *testing.T) {
+	tests := []struct {
+		units    []string
+		expected string
+	}{
+		{
+			[]string{`export let x = 1;`, `export let x = 2;`},
+			"duplicate symbol x: exported by unit0 and unit1",
+		},
+		{
+			[]string{`export let x = 1;`, `x + y`},
+			"unresolved symbol y in unit1",
+		},
+		{
+			[]string{`let y = 1;`, `y`},
+			"unresolved symbol y in unit1",
+		},
+	}
+
+	for _, tt := range tests {
+		units := []*Unit{}
+		for i, input := range tt.units {
+			units = append(units, compileUnit(t, "unit"+string(rune('0'+i)), input))
+		}
+
+		_, err := Link(units...)
+		if err == nil || err.Error() != tt.expected {
+			t.Errorf("wrong link error. want=%q, got=%v", tt.expected, err)
+		}
+	}
+}
@@ -1,13 +1,50 @@
 package main
 
 import (
+	"flag"
 	"fmt"
+	"math/rand"
+	"monkey/compiler"
+	"monkey/evaluator"
+	"monkey/module"
+	"monkey/object"
 	"monkey/repl"
+	"monkey/vm"
 	"os"
 	"os/user"
+	"path/filepath"
+)
+
+var (
+	engine     = flag.String("engine", "vm", "use 'vm' or 'eval'")
+	searchPath = flag.String("path", "", "list of directories searched for imported modules")
+	allowRead  = flag.String("allow-read", "", "list of directories whose files scripts may read")
+	allowWrite = flag.String("allow-write", "", "list of directories whose files scripts may write")
+	seed       = flag.Int64("seed", 0, "seed of the random numbers of a script, for replaying a run")
 )
 
 func main() {
+	flag.Parse()
+
+	runtime := object.NewRuntime(object.StdIO)
+	runtime.Files = object.FileAccess{
+		Read:  directories(*allowRead),
+		Write: directories(*allowWrite),
+	}
+	flag.Visit(func(f *flag.Flag) {
+		if f.Name == "seed" {
+			runtime.Rand = rand.New(rand.NewSource(*seed))
+		}
+	})
+
+	if flag.NArg() > 0 {
+		if err := run(flag.Arg(0), runtime); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		return
+	}
+
 	user, err := user.Current()
 	if err != nil {
 		panic(err)
@@ -15,5 +52,63 @@ func main() {
 	fmt.Printf("Hello %s! This is the Monkey programming language!\n",
 		user.Username)
 	fmt.Printf("Feel free to type in commands\n")
-	repl.Start(os.Stdin, os.Stdout)
+	repl.StartWithRuntime(runtime)
+}
+
+// directories splits a list of directories like the PATH environment
+// variable. Empty entries are dropped rather than taken to mean the current
+// directory.
+func directories(list string) []string {
+	dirs := []string{}
+	for _, dir := range filepath.SplitList(list) {
+		if dir != "" {
+			dirs = append(dirs, dir)
+		}
+	}
+	return dirs
+}
+
+// run executes the program in file with the selected engine.
+func run(file string, runtime *object.Runtime) error {
+	file, err := filepath.Abs(file)
+	if err != nil {
+		return err
+	}
+
+	loader := module.NewLoader(".", filepath.SplitList(*searchPath)...)
+	loader.Expand = evaluator.ExpandProgram
+	if err := loader.Enter(file); err != nil {
+		return err
+	}
+	defer loader.Leave()
+
+	program, err := loader.Parse(file)
+	if err != nil {
+		return err
+	}
+
+	switch *engine {
+	case "eval":
+		evaluator.Loader = loader
+		env := object.NewEnvironment()
+		env.SetRuntime(runtime)
+		result := evaluator.Eval(program, env)
+		if errObj, ok := result.(*object.Error); ok {
+			return fmt.Errorf("%s", errObj.Message)
+		}
+		return nil
+
+	case "vm":
+		comp := compiler.New()
+		comp.SetLoader(loader)
+		if err := comp.Compile(program); err != nil {
+			return fmt.Errorf("compilation failed: %s", err)
+		}
+		machine := vm.New(comp.Bytecode())
+		machine.SetRuntime(runtime)
+		return machine.Run()
+
+	default:
+		return fmt.Errorf("unknown engine %q", *engine)
+	}
 }
@@ -0,0 +1,122 @@
+// Package module finds and parses the files named by import statements.
+// It is shared by the evaluator and the compiler, which each keep their
+// own cache of loaded modules.
+package module
+
+import (
+	"fmt"
+	"monkey/ast"
+	"monkey/lexer"
+	"monkey/parser"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Loader resolves import paths relative to the directory of the importing
+// file first and then against each entry of SearchPath. It also tracks the
+// files currently being loaded to detect import cycles.
+type Loader struct {
+	SearchPath []string
+
+	// Expand, if set, rewrites every program Parse returns, e.g. to
+	// expand its macros.
+	Expand func(program *ast.Program) (*ast.Program, error)
+
+	root    string   // directory for imports made outside of any file
+	loading []string // files being loaded, innermost last
+}
+
+func NewLoader(root string, searchPath ...string) *Loader {
+	return &Loader{root: root, SearchPath: searchPath}
+}
+
+// Resolve returns the cleaned absolute path of the file path refers to.
+func (l *Loader) Resolve(path string) (string, error) {
+	dir := l.root
+	if len(l.loading) > 0 {
+		dir = filepath.Dir(l.loading[len(l.loading)-1])
+	}
+
+	candidates := []string{path}
+	if !filepath.IsAbs(path) {
+		candidates = []string{filepath.Join(dir, path)}
+		for _, entry := range l.SearchPath {
+			candidates = append(candidates, filepath.Join(entry, path))
+		}
+	}
+
+	for _, candidate := range candidates {
+		info, err := os.Stat(candidate)
+		if err != nil || info.IsDir() {
+			continue
+		}
+
+		abs, err := filepath.Abs(candidate)
+		if err != nil {
+			return "", err
+		}
+		return abs, nil
+	}
+
+	return "", fmt.Errorf("module not found: %s", path)
+}
+
+// Enter marks file as being loaded until the matching call to Leave. It
+// fails if file is already being loaded, which means the imports form a
+// cycle.
+func (l *Loader) Enter(file string) error {
+	for i, f := range l.loading {
+		if f == file {
+			cycle := []string{}
+			for _, f := range append(l.loading[i:], file) {
+				cycle = append(cycle, filepath.Base(f))
+			}
+			return fmt.Errorf("import cycle: %s", strings.Join(cycle, " -> "))
+		}
+	}
+
+	l.loading = append(l.loading, file)
+	return nil
+}
+
+func (l *Loader) Leave() {
+	l.loading = l.loading[:len(l.loading)-1]
+}
+
+// Parse reads and parses file and applies Expand to the program.
+func (l *Loader) Parse(file string) (*ast.Program, error) {
+	src, err := os.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+
+	p := parser.New(lexer.New(string(src)))
+	program := p.ParseProgram()
+	if len(p.Errors()) != 0 {
+		return nil, fmt.Errorf("parse errors in %s: %s",
+			filepath.Base(file), strings.Join(p.Errors(), "; "))
+	}
+
+	if l.Expand != nil {
+		expanded, err := l.Expand(program)
+		if err != nil {
+			return nil, fmt.Errorf("macro expansion failed in %s: %s",
+				filepath.Base(file), err)
+		}
+		program = expanded
+	}
+
+	return program, nil
+}
+
+// Exports returns the names a module's program exports.
+func Exports(program *ast.Program) []string {
+	names := []string{}
+	for _, s := range program.Statements {
+		if let, ok := s.(*ast.LetStatement); ok && let.Exported {
+			names = append(names, let.Name.Value)
+		}
+	}
+	return names
+}
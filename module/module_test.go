@@ -0,0 +1,117 @@
+package module
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func writeFiles(t *testing.T, files map[string]string) string {
+	t.Helper()
+
+	dir := t.TempDir()
+	for name, src := range files {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(src), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+func TestResolve(t *testing.T) {
+	dir := writeFiles(t, map[string]string{
+		"main.mk":         ``,
+		"lib/a.mk":        ``,
+		"lib/b.mk":        ``,
+		"vendor/b.mk":     ``,
+		"vendor/extra.mk": ``,
+	})
+
+	l := NewLoader(dir, filepath.Join(dir, "vendor"))
+
+	tests := []struct {
+		importer string
+		path     string
+		expected string
+	}{
+		{"", "lib/a.mk", "lib/a.mk"},
+		{"main.mk", "lib/a.mk", "lib/a.mk"},
+		{"lib/a.mk", "b.mk", "lib/b.mk"},
+		{"lib/a.mk", "../main.mk", "main.mk"},
+		{"lib/a.mk", "extra.mk", "vendor/extra.mk"},
+		{"main.mk", "b.mk", "vendor/b.mk"},
+	}
+
+	for _, tt := range tests {
+		if tt.importer != "" {
+			l.Enter(filepath.Join(dir, tt.importer))
+		}
+
+		file, err := l.Resolve(tt.path)
+		if err != nil {
+			t.Errorf("Resolve(%q) from %q failed: %s", tt.path, tt.importer, err)
+		} else if file != filepath.Join(dir, tt.expected) {
+			t.Errorf("Resolve(%q) from %q wrong. want=%q, got=%q",
+				tt.path, tt.importer, filepath.Join(dir, tt.expected), file)
+		}
+
+		if tt.importer != "" {
+			l.Leave()
+		}
+	}
+
+	_, err := l.Resolve("missing.mk")
+	if err == nil || err.Error() != "module not found: missing.mk" {
+		t.Errorf("wrong error for missing module. got=%v", err)
+	}
+}
+
+func TestEnterDetectsCycles(t *testing.T) {
+	l := NewLoader("/")
+
+	for _, f := range []string{"/x/a.mk", "/x/b.mk", "/x/c.mk"} {
+		if err := l.Enter(f); err != nil {
+			t.Fatalf("Enter(%q) failed: %s", f, err)
+		}
+	}
+
+	err := l.Enter("/x/b.mk")
+	if err == nil || err.Error() != "import cycle: b.mk -> c.mk -> b.mk" {
+		t.Errorf("wrong cycle error. got=%v", err)
+	}
+
+	l.Leave()
+	l.Leave()
+	if err := l.Enter("/x/b.mk"); err != nil {
+		t.Errorf("Enter after Leave failed: %s", err)
+	}
+}
+
+func TestParseAndExports(t *testing.T) {
+	dir := writeFiles(t, map[string]string{
+		"ok.mk":  `let a = 1; export let b = 2; export const c = 3;`,
+		"bad.mk": `let x 1;`,
+	})
+
+	l := NewLoader(dir)
+
+	program, err := l.Parse(filepath.Join(dir, "ok.mk"))
+	if err != nil {
+		t.Fatalf("Parse failed: %s", err)
+	}
+
+	exports := Exports(program)
+	if len(exports) != 2 || exports[0] != "b" || exports[1] != "c" {
+		t.Errorf("wrong exports. got=%q", exports)
+	}
+
+	_, err = l.Parse(filepath.Join(dir, "bad.mk"))
+	want := "parse errors in bad.mk: expected next token to be =, got INT instead"
+	if err == nil || err.Error() != want {
+		t.Errorf("wrong parse error. want=%q, got=%v", want, err)
+	}
+}
@@ -0,0 +1,34 @@
+package object
+
+import "time"
+
+var clockBuiltins = []BuiltinDefinition{
+	{
+		"now",
+		&Builtin{WithContext: func(ctx *Context, args ...Object) Object {
+			if len(args) != 0 {
+				return newError("wrong number of arguments. got=%d, want=0",
+					len(args))
+			}
+
+			return &Integer{Value: ctx.Clock.Now().UnixMilli()}
+		},
+		},
+	},
+	{
+		"sleep",
+		&Builtin{WithContext: func(ctx *Context, args ...Object) Object {
+			ints, err := integerArgs("sleep", args, 1)
+			if err != nil {
+				return err
+			}
+			if ints[0] < 0 {
+				return newError("argument to `sleep` must not be negative, got %d", ints[0])
+			}
+
+			ctx.Clock.Sleep(time.Duration(ints[0]) * time.Millisecond)
+			return nil
+		},
+		},
+	},
+}
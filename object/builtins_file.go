@@ -0,0 +1,195 @@
+package object
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// FileAccess is the host's policy for the filesystem builtins: they may
+// only touch files below the directories it allows. Symbolic links are
+// resolved before a path is checked. The zero value allows nothing.
+type FileAccess struct {
+	Read  []string // directories whose files may be read
+	Write []string // directories whose files may be written
+}
+
+var fileBuiltins = []BuiltinDefinition{
+	{
+		"read_file",
+		&Builtin{WithContext: func(ctx *Context, args ...Object) Object {
+			path, err := allowedPath("read_file", args, 1, ctx.Files.Read, "read")
+			if err != nil {
+				return err
+			}
+
+			content, readErr := os.ReadFile(path)
+			if readErr != nil {
+				return fileError(args[0], readErr)
+			}
+
+			return &String{Value: string(content)}
+		},
+		},
+	},
+	{
+		"read_lines",
+		&Builtin{WithContext: func(ctx *Context, args ...Object) Object {
+			path, err := allowedPath("read_lines", args, 1, ctx.Files.Read, "read")
+			if err != nil {
+				return err
+			}
+
+			content, readErr := os.ReadFile(path)
+			if readErr != nil {
+				return fileError(args[0], readErr)
+			}
+
+			text := strings.TrimSuffix(string(content), "\n")
+			if text == "" {
+				return &Array{Elements: []Object{}}
+			}
+
+			lines := strings.Split(text, "\n")
+			for i, line := range lines {
+				lines[i] = strings.TrimSuffix(line, "\r")
+			}
+
+			return stringArray(lines)
+		},
+		},
+	},
+	{
+		"write_file",
+		&Builtin{WithContext: func(ctx *Context, args ...Object) Object {
+			path, err := allowedPath("write_file", args, 2, ctx.Files.Write, "write")
+			if err != nil {
+				return err
+			}
+			content, ok := args[1].(*String)
+			if !ok {
+				return newError("argument to `write_file` must be STRING, got %s",
+					args[1].Type())
+			}
+
+			if writeErr := os.WriteFile(path, []byte(content.Value), 0644); writeErr != nil {
+				return fileError(args[0], writeErr)
+			}
+
+			return nil
+		},
+		},
+	},
+	{
+		"list_dir",
+		&Builtin{WithContext: func(ctx *Context, args ...Object) Object {
+			path, err := allowedPath("list_dir", args, 1, ctx.Files.Read, "read")
+			if err != nil {
+				return err
+			}
+
+			entries, readErr := os.ReadDir(path)
+			if readErr != nil {
+				return fileError(args[0], readErr)
+			}
+
+			names := make([]string, len(entries))
+			for i, entry := range entries {
+				names[i] = entry.Name()
+			}
+
+			return stringArray(names)
+		},
+		},
+	},
+	{
+		"exists",
+		&Builtin{WithContext: func(ctx *Context, args ...Object) Object {
+			path, err := allowedPath("exists", args, 1, ctx.Files.Read, "read")
+			if err != nil {
+				return err
+			}
+
+			_, statErr := os.Stat(path)
+			return NativeBool(statErr == nil)
+		},
+		},
+	},
+}
+
+// allowedPath checks that args are count arguments for the builtin name,
+// the first of which is a path below one of roots, and returns the path
+// with symbolic links resolved.
+func allowedPath(name string, args []Object, count int, roots []string, access string) (string, *Error) {
+	if len(args) != count {
+		return "", newError("wrong number of arguments. got=%d, want=%d",
+			len(args), count)
+	}
+
+	arg, ok := args[0].(*String)
+	if !ok {
+		return "", newError("argument to `%s` must be STRING, got %s",
+			name, args[0].Type())
+	}
+
+	path, err := resolvePath(arg.Value)
+	if err == nil {
+		for _, root := range roots {
+			if root == "" {
+				// It would resolve to the working directory.
+				continue
+			}
+			root, rootErr := resolvePath(root)
+			if rootErr != nil {
+				continue
+			}
+			rel, relErr := filepath.Rel(root, path)
+			if relErr == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+				return path, nil
+			}
+		}
+	}
+
+	return "", &Error{
+		Message: fmt.Sprintf("%s access to %q denied", access, arg.Value),
+		Kind:    PERMISSION_ERROR_KIND,
+	}
+}
+
+// resolvePath returns the absolute form of path with symbolic links
+// resolved. The last element need not exist, so that files can be
+// created.
+func resolvePath(path string) (string, error) {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return "", err
+	}
+
+	resolved, err := filepath.EvalSymlinks(abs)
+	if err == nil {
+		return resolved, nil
+	}
+	if _, statErr := os.Lstat(abs); statErr == nil {
+		// A dangling symbolic link could point anywhere.
+		return "", err
+	}
+
+	// A missing parent makes any access fail, so abs is as good as any.
+	dir, err := filepath.EvalSymlinks(filepath.Dir(abs))
+	if err != nil {
+		return abs, nil
+	}
+	return filepath.Join(dir, filepath.Base(abs)), nil
+}
+
+// fileError reports err, naming the file as the script did.
+func fileError(path Object, err error) *Error {
+	var pathErr *fs.PathError
+	if errors.As(err, &pathErr) {
+		return newError("%s %s: %s", pathErr.Op, ToString(path), pathErr.Err)
+	}
+	return newError("%s", err)
+}
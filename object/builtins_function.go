@@ -0,0 +1,229 @@
+package object
+
+import "sort"
+
+var functionBuiltins = []BuiltinDefinition{
+	{
+		"map",
+		&Builtin{WithContext: func(ctx *Context, args ...Object) Object {
+			arr, fn, err := arrayAndFunctionArgs("map", args)
+			if err != nil {
+				return err
+			}
+
+			mapped := make([]Object, len(arr.Elements))
+			for i, e := range arr.Elements {
+				result := ctx.Apply(fn, e)
+				if isError(result) {
+					return result
+				}
+				mapped[i] = result
+			}
+
+			return &Array{Elements: mapped}
+		},
+		},
+	},
+	{
+		"filter",
+		&Builtin{WithContext: func(ctx *Context, args ...Object) Object {
+			arr, fn, err := arrayAndFunctionArgs("filter", args)
+			if err != nil {
+				return err
+			}
+
+			filtered := []Object{}
+			for _, e := range arr.Elements {
+				result := ctx.Apply(fn, e)
+				if isError(result) {
+					return result
+				}
+				if isTruthy(result) {
+					filtered = append(filtered, e)
+				}
+			}
+
+			return &Array{Elements: filtered}
+		},
+		},
+	},
+	{
+		"reduce",
+		&Builtin{WithContext: func(ctx *Context, args ...Object) Object {
+			if len(args) != 3 {
+				return newError("wrong number of arguments. got=%d, want=3",
+					len(args))
+			}
+
+			arr, fn, err := arrayAndFunctionArgs("reduce", []Object{args[0], args[2]})
+			if err != nil {
+				return err
+			}
+
+			acc := args[1]
+			for _, e := range arr.Elements {
+				acc = ctx.Apply(fn, acc, e)
+				if isError(acc) {
+					return acc
+				}
+			}
+
+			return acc
+		},
+		},
+	},
+	{
+		"sort_by",
+		&Builtin{WithContext: func(ctx *Context, args ...Object) Object {
+			arr, fn, err := arrayAndFunctionArgs("sort_by", args)
+			if err != nil {
+				return err
+			}
+
+			keys := make([]Object, len(arr.Elements))
+			for i, e := range arr.Elements {
+				key := ctx.Apply(fn, e)
+				if isError(key) {
+					return key
+				}
+				if key.Type() != INTEGER_OBJ && key.Type() != STRING_OBJ {
+					return newError("keys of `sort_by` must be INTEGER or STRING, got %s",
+						key.Type())
+				}
+				if i > 0 && key.Type() != keys[0].Type() {
+					return newError("keys of `sort_by` must have the same type, got %s and %s",
+						keys[0].Type(), key.Type())
+				}
+				keys[i] = key
+			}
+
+			order := make([]int, len(keys))
+			for i := range order {
+				order[i] = i
+			}
+			sort.SliceStable(order, func(i, j int) bool {
+				return keyLess(keys[order[i]], keys[order[j]])
+			})
+
+			sorted := make([]Object, len(order))
+			for i, index := range order {
+				sorted[i] = arr.Elements[index]
+			}
+
+			return &Array{Elements: sorted}
+		},
+		},
+	},
+	{
+		"any",
+		&Builtin{WithContext: func(ctx *Context, args ...Object) Object {
+			arr, fn, err := arrayAndFunctionArgs("any", args)
+			if err != nil {
+				return err
+			}
+
+			for _, e := range arr.Elements {
+				result := ctx.Apply(fn, e)
+				if isError(result) {
+					return result
+				}
+				if isTruthy(result) {
+					return TRUE
+				}
+			}
+
+			return FALSE
+		},
+		},
+	},
+	{
+		"all",
+		&Builtin{WithContext: func(ctx *Context, args ...Object) Object {
+			arr, fn, err := arrayAndFunctionArgs("all", args)
+			if err != nil {
+				return err
+			}
+
+			for _, e := range arr.Elements {
+				result := ctx.Apply(fn, e)
+				if isError(result) {
+					return result
+				}
+				if !isTruthy(result) {
+					return FALSE
+				}
+			}
+
+			return TRUE
+		},
+		},
+	},
+	{
+		"each",
+		&Builtin{WithContext: func(ctx *Context, args ...Object) Object {
+			arr, fn, err := arrayAndFunctionArgs("each", args)
+			if err != nil {
+				return err
+			}
+
+			for _, e := range arr.Elements {
+				if result := ctx.Apply(fn, e); isError(result) {
+					return result
+				}
+			}
+
+			return nil
+		},
+		},
+	},
+}
+
+// arrayAndFunctionArgs checks that args are an array and a function for
+// the builtin name.
+func arrayAndFunctionArgs(name string, args []Object) (*Array, Object, *Error) {
+	if len(args) != 2 {
+		return nil, nil, newError("wrong number of arguments. got=%d, want=2",
+			len(args))
+	}
+
+	arr, ok := args[0].(*Array)
+	if !ok {
+		return nil, nil, newError("argument to `%s` must be ARRAY, got %s",
+			name, args[0].Type())
+	}
+
+	switch args[1].(type) {
+	case *Function, *Closure, *Builtin:
+		return arr, args[1], nil
+	default:
+		return nil, nil, newError("argument to `%s` must be a function, got %s",
+			name, args[1].Type())
+	}
+}
+
+// keyLess orders two integer or two string sort keys.
+func keyLess(a, b Object) bool {
+	switch a := a.(type) {
+	case *Integer:
+		return a.Value < b.(*Integer).Value
+	case *String:
+		return a.Value < b.(*String).Value
+	default:
+		return false
+	}
+}
+
+func isError(obj Object) bool {
+	return obj != nil && obj.Type() == ERROR_OBJ
+}
+
+func isTruthy(obj Object) bool {
+	switch obj := obj.(type) {
+	case *Boolean:
+		return obj.Value
+	case *Null:
+		return false
+	default:
+		return true
+	}
+}
@@ -0,0 +1,138 @@
+package object
+
+var hashBuiltins = []BuiltinDefinition{
+	{
+		"keys",
+		&Builtin{Fn: func(args ...Object) Object {
+			hash, err := hashArg("keys", args, 1)
+			if err != nil {
+				return err
+			}
+
+			keys := []Object{}
+			for _, pair := range hash.Ordered() {
+				keys = append(keys, pair.Key)
+			}
+
+			return &Array{Elements: keys}
+		},
+		},
+	},
+	{
+		"values",
+		&Builtin{Fn: func(args ...Object) Object {
+			hash, err := hashArg("values", args, 1)
+			if err != nil {
+				return err
+			}
+
+			values := []Object{}
+			for _, pair := range hash.Ordered() {
+				values = append(values, pair.Value)
+			}
+
+			return &Array{Elements: values}
+		},
+		},
+	},
+	{
+		"items",
+		&Builtin{Fn: func(args ...Object) Object {
+			hash, err := hashArg("items", args, 1)
+			if err != nil {
+				return err
+			}
+
+			items := []Object{}
+			for _, pair := range hash.Ordered() {
+				items = append(items, &Array{Elements: []Object{pair.Key, pair.Value}})
+			}
+
+			return &Array{Elements: items}
+		},
+		},
+	},
+	{
+		"has",
+		&Builtin{Fn: func(args ...Object) Object {
+			hash, err := hashArg("has", args, 2)
+			if err != nil {
+				return err
+			}
+			key, ok := AsHashable(args[1])
+			if !ok {
+				return newError("unusable as hash key: %s", args[1].Type())
+			}
+
+			_, found := hash.Get(key)
+			return NativeBool(found)
+		},
+		},
+	},
+	{
+		"delete",
+		&Builtin{Fn: func(args ...Object) Object {
+			hash, err := hashArg("delete", args, 2)
+			if err != nil {
+				return err
+			}
+			key, ok := AsHashable(args[1])
+			if !ok {
+				return newError("unusable as hash key: %s", args[1].Type())
+			}
+
+			deleted := NewHash()
+			for _, pair := range hash.Ordered() {
+				if !Equal(pair.Key, key) {
+					deleted.Set(pair.Key.(Hashable), pair.Value)
+				}
+			}
+
+			return deleted
+		},
+		},
+	},
+	{
+		"merge",
+		&Builtin{Fn: func(args ...Object) Object {
+			if len(args) != 2 {
+				return newError("wrong number of arguments. got=%d, want=2",
+					len(args))
+			}
+
+			// Keys of the second hash win, but keys of the first keep
+			// their position.
+			merged := NewHash()
+			for _, arg := range args {
+				hash, ok := arg.(*Hash)
+				if !ok {
+					return newError("argument to `merge` must be HASH, got %s",
+						arg.Type())
+				}
+				for _, pair := range hash.Ordered() {
+					merged.Set(pair.Key.(Hashable), pair.Value)
+				}
+			}
+
+			return merged
+		},
+		},
+	},
+}
+
+// hashArg checks that args are count arguments for the builtin name, the
+// first of which is a hash, and returns the hash.
+func hashArg(name string, args []Object, count int) (*Hash, *Error) {
+	if len(args) != count {
+		return nil, newError("wrong number of arguments. got=%d, want=%d",
+			len(args), count)
+	}
+
+	hash, ok := args[0].(*Hash)
+	if !ok {
+		return nil, newError("argument to `%s` must be HASH, got %s",
+			name, args[0].Type())
+	}
+
+	return hash, nil
+}
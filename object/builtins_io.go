@@ -0,0 +1,66 @@
+package object
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+)
+
+var ioBuiltins = []BuiltinDefinition{
+	{
+		"print",
+		&Builtin{WithContext: func(ctx *Context, args ...Object) Object {
+			for _, arg := range args {
+				fmt.Fprint(ctx.IO.Out, arg.Inspect())
+			}
+
+			return nil
+		},
+		},
+	},
+	{
+		"input",
+		&Builtin{WithContext: func(ctx *Context, args ...Object) Object {
+			if len(args) > 1 {
+				return newError("wrong number of arguments. got=%d, want=0..1",
+					len(args))
+			}
+
+			if len(args) == 1 {
+				fmt.Fprint(ctx.IO.Out, args[0].Inspect())
+			}
+
+			return readLine(ctx.IO.In)
+		},
+		},
+	},
+	{
+		"read_line",
+		&Builtin{WithContext: func(ctx *Context, args ...Object) Object {
+			if len(args) != 0 {
+				return newError("wrong number of arguments. got=%d, want=0",
+					len(args))
+			}
+
+			return readLine(ctx.IO.In)
+		},
+		},
+	},
+}
+
+// readLine reads the next line from in without its line ending. It
+// returns nil at the end of the input.
+func readLine(in *bufio.Reader) Object {
+	line, err := in.ReadString('\n')
+	if err == io.EOF && line == "" {
+		return nil
+	}
+	if err != nil && err != io.EOF {
+		return newError("%s", err)
+	}
+
+	line = strings.TrimSuffix(line, "\n")
+	line = strings.TrimSuffix(line, "\r")
+	return &String{Value: line}
+}
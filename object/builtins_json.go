@@ -0,0 +1,185 @@
+package object
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+)
+
+var jsonBuiltins = []BuiltinDefinition{
+	{
+		"json_encode",
+		&Builtin{Fn: func(args ...Object) Object {
+			if len(args) != 1 && len(args) != 2 {
+				return newError("wrong number of arguments. got=%d, want=1..2",
+					len(args))
+			}
+
+			var out bytes.Buffer
+			if err := encodeJSON(&out, args[0]); err != nil {
+				return newError("%s", err)
+			}
+
+			if len(args) == 2 {
+				indent, ok := args[1].(*Integer)
+				if !ok {
+					return newError("argument to `json_encode` must be INTEGER, got %s",
+						args[1].Type())
+				}
+				if indent.Value < 0 {
+					return newError("argument to `json_encode` must not be negative, got %d",
+						indent.Value)
+				}
+
+				var indented bytes.Buffer
+				json.Indent(&indented, out.Bytes(), "", strings.Repeat(" ", int(indent.Value)))
+				out = indented
+			}
+
+			return &String{Value: out.String()}
+		},
+		},
+	},
+	{
+		"json_decode",
+		&Builtin{Fn: func(args ...Object) Object {
+			strs, err := stringArgs("json_decode", args, 1)
+			if err != nil {
+				return err
+			}
+
+			dec := json.NewDecoder(strings.NewReader(strs[0]))
+			dec.UseNumber()
+
+			value, decodeErr := decodeJSON(dec)
+			if decodeErr == nil {
+				if _, tokenErr := dec.Token(); tokenErr != io.EOF {
+					decodeErr = errors.New("unexpected data after value")
+				}
+			}
+			if decodeErr != nil {
+				return newError("invalid JSON: %s", decodeErr)
+			}
+
+			return value
+		},
+		},
+	},
+}
+
+// encodeJSON writes obj to out as compact JSON. Hash pairs are written in
+// insertion order.
+func encodeJSON(out *bytes.Buffer, obj Object) error {
+	switch obj := obj.(type) {
+	case *Null:
+		out.WriteString("null")
+
+	case *Boolean, *Integer:
+		out.WriteString(obj.Inspect())
+
+	case *String:
+		enc := json.NewEncoder(out)
+		enc.SetEscapeHTML(false)
+		enc.Encode(obj.Value)
+		out.Truncate(out.Len() - 1) // Encode ends the value with a newline
+
+	case *Array:
+		out.WriteByte('[')
+		for i, e := range obj.Elements {
+			if i > 0 {
+				out.WriteByte(',')
+			}
+			if err := encodeJSON(out, e); err != nil {
+				return err
+			}
+		}
+		out.WriteByte(']')
+
+	case *Hash:
+		out.WriteByte('{')
+		for i, pair := range obj.Ordered() {
+			if pair.Key.Type() != STRING_OBJ {
+				return fmt.Errorf("JSON object keys must be STRING, got %s",
+					pair.Key.Type())
+			}
+			if i > 0 {
+				out.WriteByte(',')
+			}
+			encodeJSON(out, pair.Key)
+			out.WriteByte(':')
+			if err := encodeJSON(out, pair.Value); err != nil {
+				return err
+			}
+		}
+		out.WriteByte('}')
+
+	default:
+		return fmt.Errorf("cannot encode %s as JSON", typeName(obj))
+	}
+
+	return nil
+}
+
+// decodeJSON reads the next JSON value from dec. Objects become hashes
+// with their keys in source order.
+func decodeJSON(dec *json.Decoder) (Object, error) {
+	tok, err := dec.Token()
+	if err == io.EOF {
+		return nil, errors.New("unexpected end of JSON input")
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	switch tok := tok.(type) {
+	case nil:
+		return NULL, nil
+
+	case bool:
+		return NativeBool(tok), nil
+
+	case string:
+		return &String{Value: tok}, nil
+
+	case json.Number:
+		value, err := tok.Int64()
+		if err != nil {
+			return nil, fmt.Errorf("number %s is not an integer", tok)
+		}
+		return &Integer{Value: value}, nil
+
+	case json.Delim:
+		if tok == '[' {
+			elements := []Object{}
+			for dec.More() {
+				e, err := decodeJSON(dec)
+				if err != nil {
+					return nil, err
+				}
+				elements = append(elements, e)
+			}
+			dec.Token() // ]
+			return &Array{Elements: elements}, nil
+		}
+
+		hash := NewHash()
+		for dec.More() {
+			key, err := dec.Token()
+			if err != nil {
+				return nil, err
+			}
+			value, err := decodeJSON(dec)
+			if err != nil {
+				return nil, err
+			}
+			hash.Set(&String{Value: key.(string)}, value)
+		}
+		dec.Token() // }
+		return hash, nil
+	}
+
+	return nil, fmt.Errorf("unexpected token %v", tok)
+}
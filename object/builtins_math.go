@@ -0,0 +1,234 @@
+package object
+
+import "math"
+
+var mathBuiltins = []BuiltinDefinition{
+	{
+		"abs",
+		&Builtin{Fn: func(args ...Object) Object {
+			ints, err := integerArgs("abs", args, 1)
+			if err != nil {
+				return err
+			}
+
+			n := ints[0]
+			if n == math.MinInt64 {
+				return newError("integer overflow in `abs`")
+			}
+			if n < 0 {
+				n = -n
+			}
+
+			return &Integer{Value: n}
+		},
+		},
+	},
+	{
+		"min",
+		&Builtin{Fn: func(args ...Object) Object {
+			ints, err := integerValues("min", args)
+			if err != nil {
+				return err
+			}
+
+			min := ints[0]
+			for _, n := range ints[1:] {
+				if n < min {
+					min = n
+				}
+			}
+
+			return &Integer{Value: min}
+		},
+		},
+	},
+	{
+		"max",
+		&Builtin{Fn: func(args ...Object) Object {
+			ints, err := integerValues("max", args)
+			if err != nil {
+				return err
+			}
+
+			max := ints[0]
+			for _, n := range ints[1:] {
+				if n > max {
+					max = n
+				}
+			}
+
+			return &Integer{Value: max}
+		},
+		},
+	},
+	{
+		"pow",
+		&Builtin{Fn: func(args ...Object) Object {
+			ints, err := integerArgs("pow", args, 2)
+			if err != nil {
+				return err
+			}
+
+			base, exp := ints[0], ints[1]
+			if exp < 0 {
+				return newError("argument to `pow` must not be negative, got %d", exp)
+			}
+
+			switch {
+			case exp == 0 || base == 1:
+				return &Integer{Value: 1}
+			case base == 0:
+				return &Integer{Value: 0}
+			case base == -1 && exp%2 == 0:
+				return &Integer{Value: 1}
+			case base == -1:
+				return &Integer{Value: -1}
+			}
+
+			// Any other base overflows within 64 multiplications.
+			result := int64(1)
+			for ; exp > 0; exp-- {
+				product, ok := multiply(result, base)
+				if !ok {
+					return newError("integer overflow in `pow`")
+				}
+				result = product
+			}
+
+			return &Integer{Value: result}
+		},
+		},
+	},
+	{
+		"gcd",
+		&Builtin{Fn: func(args ...Object) Object {
+			ints, err := integerArgs("gcd", args, 2)
+			if err != nil {
+				return err
+			}
+
+			// Work with non-positive values, which cannot overflow.
+			a, b := ints[0], ints[1]
+			if a > 0 {
+				a = -a
+			}
+			if b > 0 {
+				b = -b
+			}
+			for b != 0 {
+				a, b = b, a%b
+			}
+			if a == math.MinInt64 {
+				return newError("integer overflow in `gcd`")
+			}
+
+			return &Integer{Value: -a}
+		},
+		},
+	},
+	{
+		"clamp",
+		&Builtin{Fn: func(args ...Object) Object {
+			ints, err := integerArgs("clamp", args, 3)
+			if err != nil {
+				return err
+			}
+
+			n, low, high := ints[0], ints[1], ints[2]
+			if low > high {
+				return newError("bounds of `clamp` are out of order: %d > %d", low, high)
+			}
+
+			switch {
+			case n < low:
+				n = low
+			case n > high:
+				n = high
+			}
+
+			return &Integer{Value: n}
+		},
+		},
+	},
+	{
+		"sum",
+		&Builtin{Fn: func(args ...Object) Object {
+			if len(args) != 1 {
+				return newError("wrong number of arguments. got=%d, want=1",
+					len(args))
+			}
+			arr, ok := args[0].(*Array)
+			if !ok {
+				return newError("argument to `sum` must be ARRAY, got %s",
+					args[0].Type())
+			}
+
+			ints, err := integerArgs("sum", arr.Elements, len(arr.Elements))
+			if err != nil {
+				return err
+			}
+
+			sum := int64(0)
+			for _, n := range ints {
+				if (n > 0 && sum > math.MaxInt64-n) || (n < 0 && sum < math.MinInt64-n) {
+					return newError("integer overflow in `sum`")
+				}
+				sum += n
+			}
+
+			return &Integer{Value: sum}
+		},
+		},
+	},
+}
+
+// integerArgs checks that args are count integers for the builtin name
+// and returns their values.
+func integerArgs(name string, args []Object, count int) ([]int64, *Error) {
+	if len(args) != count {
+		return nil, newError("wrong number of arguments. got=%d, want=%d",
+			len(args), count)
+	}
+
+	ints := make([]int64, count)
+	for i, arg := range args {
+		n, ok := arg.(*Integer)
+		if !ok {
+			return nil, newError("argument to `%s` must be INTEGER, got %s",
+				name, arg.Type())
+		}
+		ints[i] = n.Value
+	}
+
+	return ints, nil
+}
+
+// integerValues returns the integers the builtin name was passed, either
+// as its arguments or as the elements of a single array argument.
+func integerValues(name string, args []Object) ([]int64, *Error) {
+	if len(args) == 0 {
+		return nil, newError("%s", WrongArgumentCount(0, 1, -1))
+	}
+
+	if arr, ok := args[0].(*Array); ok && len(args) == 1 {
+		if len(arr.Elements) == 0 {
+			return nil, newError("argument to `%s` must not be empty", name)
+		}
+		args = arr.Elements
+	}
+
+	return integerArgs(name, args, len(args))
+}
+
+// multiply returns a * b, reporting false if the product overflows.
+func multiply(a, b int64) (int64, bool) {
+	if a == 0 || b == 0 {
+		return 0, true
+	}
+
+	product := a * b
+	if product/b != a || (a == -1 && b == math.MinInt64) || (b == -1 && a == math.MinInt64) {
+		return 0, false
+	}
+	return product, true
+}
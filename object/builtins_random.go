@@ -0,0 +1,77 @@
+package object
+
+import "math"
+
+var randomBuiltins = []BuiltinDefinition{
+	{
+		"rand_int",
+		&Builtin{WithContext: func(ctx *Context, args ...Object) Object {
+			ints, err := integerArgs("rand_int", args, 2)
+			if err != nil {
+				return err
+			}
+
+			low, high := ints[0], ints[1]
+			if low > high {
+				return newError("bounds of `rand_int` are out of order: %d > %d", low, high)
+			}
+			if uint64(high-low) >= math.MaxInt64 {
+				return newError("range of `rand_int` is too large: %d..%d", low, high)
+			}
+
+			return &Integer{Value: low + ctx.Rand.Int63n(high-low+1)}
+		},
+		},
+	},
+	{
+		"shuffle",
+		&Builtin{WithContext: func(ctx *Context, args ...Object) Object {
+			arr, err := arrayArg("shuffle", args)
+			if err != nil {
+				return err
+			}
+
+			shuffled := make([]Object, len(arr.Elements))
+			copy(shuffled, arr.Elements)
+			for i := len(shuffled) - 1; i > 0; i-- {
+				j := ctx.Rand.Int63n(int64(i + 1))
+				shuffled[i], shuffled[j] = shuffled[j], shuffled[i]
+			}
+
+			return &Array{Elements: shuffled}
+		},
+		},
+	},
+	{
+		"choice",
+		&Builtin{WithContext: func(ctx *Context, args ...Object) Object {
+			arr, err := arrayArg("choice", args)
+			if err != nil {
+				return err
+			}
+			if len(arr.Elements) == 0 {
+				return newError("argument to `choice` must not be empty")
+			}
+
+			return arr.Elements[ctx.Rand.Int63n(int64(len(arr.Elements)))]
+		},
+		},
+	},
+}
+
+// arrayArg checks that args are a single array for the builtin name and
+// returns it.
+func arrayArg(name string, args []Object) (*Array, *Error) {
+	if len(args) != 1 {
+		return nil, newError("wrong number of arguments. got=%d, want=1",
+			len(args))
+	}
+
+	arr, ok := args[0].(*Array)
+	if !ok {
+		return nil, newError("argument to `%s` must be ARRAY, got %s",
+			name, args[0].Type())
+	}
+
+	return arr, nil
+}
@@ -0,0 +1,169 @@
+package object
+
+import (
+	"errors"
+	"regexp"
+	"regexp/syntax"
+	"sync"
+)
+
+var regexpBuiltins = []BuiltinDefinition{
+	{
+		"re_match",
+		&Builtin{Fn: func(args ...Object) Object {
+			re, s, err := regexpArgs("re_match", args, 2)
+			if err != nil {
+				return err
+			}
+
+			match := re.FindStringSubmatchIndex(s)
+			if match == nil {
+				return nil
+			}
+
+			return groups(re, s, match)
+		},
+		},
+	},
+	{
+		"re_find_all",
+		&Builtin{Fn: func(args ...Object) Object {
+			re, s, err := regexpArgs("re_find_all", args, 2)
+			if err != nil {
+				return err
+			}
+
+			matches := []Object{}
+			for _, match := range re.FindAllStringSubmatchIndex(s, -1) {
+				if re.NumSubexp() == 0 {
+					matches = append(matches, &String{Value: s[match[0]:match[1]]})
+				} else {
+					matches = append(matches, groups(re, s, match))
+				}
+			}
+
+			return &Array{Elements: matches}
+		},
+		},
+	},
+	{
+		"re_replace",
+		&Builtin{Fn: func(args ...Object) Object {
+			re, s, err := regexpArgs("re_replace", args, 3)
+			if err != nil {
+				return err
+			}
+			replacement, ok := args[2].(*String)
+			if !ok {
+				return newError("argument to `re_replace` must be STRING, got %s",
+					args[2].Type())
+			}
+
+			return &String{Value: re.ReplaceAllString(s, replacement.Value)}
+		},
+		},
+	},
+	{
+		"re_split",
+		&Builtin{Fn: func(args ...Object) Object {
+			re, s, err := regexpArgs("re_split", args, 2)
+			if err != nil {
+				return err
+			}
+
+			return stringArray(re.Split(s, -1))
+		},
+		},
+	},
+}
+
+// regexpArgs checks that args are count arguments for the builtin name,
+// the first two of which are a pattern and a string, and returns the
+// compiled pattern and the string.
+func regexpArgs(name string, args []Object, count int) (*regexp.Regexp, string, *Error) {
+	if len(args) != count {
+		return nil, "", newError("wrong number of arguments. got=%d, want=%d",
+			len(args), count)
+	}
+
+	strs, err := stringArgs(name, args[:2], 2)
+	if err != nil {
+		return nil, "", err
+	}
+
+	re, compileErr := compileRegexp(strs[0])
+	if compileErr != nil {
+		var syntaxErr *syntax.Error
+		if errors.As(compileErr, &syntaxErr) {
+			return nil, "", newError("invalid regular expression %q: %s",
+				strs[0], syntaxErr.Code)
+		}
+		return nil, "", newError("invalid regular expression %q: %s", strs[0], compileErr)
+	}
+
+	return re, strs[1], nil
+}
+
+// groups returns the text of the groups of match: an array of the whole
+// match and the capture groups, or if the pattern names any of its groups
+// a hash keyed by the names and the numbers of the unnamed groups. Groups
+// that did not take part in the match are null.
+func groups(re *regexp.Regexp, s string, match []int) Object {
+	texts := make([]Object, len(match)/2)
+	for i := range texts {
+		if match[2*i] < 0 {
+			texts[i] = NULL
+		} else {
+			texts[i] = &String{Value: s[match[2*i]:match[2*i+1]]}
+		}
+	}
+
+	named := false
+	for _, name := range re.SubexpNames() {
+		named = named || name != ""
+	}
+	if !named {
+		return &Array{Elements: texts}
+	}
+
+	hash := NewHash()
+	for i, name := range re.SubexpNames() {
+		if name == "" {
+			hash.Set(&Integer{Value: int64(i)}, texts[i])
+		} else {
+			hash.Set(&String{Value: name}, texts[i])
+		}
+	}
+	return hash
+}
+
+// maxCachedRegexps bounds the cache of compiled patterns, which is emptied
+// when it is full.
+const maxCachedRegexps = 256
+
+var regexpCache = struct {
+	sync.Mutex
+	patterns map[string]*regexp.Regexp
+}{patterns: map[string]*regexp.Regexp{}}
+
+// compileRegexp compiles pattern, reusing the result of earlier calls.
+func compileRegexp(pattern string) (*regexp.Regexp, error) {
+	regexpCache.Lock()
+	defer regexpCache.Unlock()
+
+	if re, ok := regexpCache.patterns[pattern]; ok {
+		return re, nil
+	}
+
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(regexpCache.patterns) >= maxCachedRegexps {
+		regexpCache.patterns = map[string]*regexp.Regexp{}
+	}
+	regexpCache.patterns[pattern] = re
+
+	return re, nil
+}
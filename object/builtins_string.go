@@ -0,0 +1,222 @@
+package object
+
+import "strings"
+
+// maxStringLength bounds the strings builtins build from a count, which
+// could otherwise exhaust memory.
+const maxStringLength = 1 << 30
+
+var stringBuiltins = []BuiltinDefinition{
+	{
+		"split",
+		&Builtin{Fn: func(args ...Object) Object {
+			strs, err := stringArgs("split", args, 2)
+			if err != nil {
+				return err
+			}
+
+			parts := strings.Split(strs[0], strs[1])
+			return stringArray(parts)
+		},
+		},
+	},
+	{
+		"join",
+		&Builtin{Fn: func(args ...Object) Object {
+			if len(args) != 2 {
+				return newError("wrong number of arguments. got=%d, want=2",
+					len(args))
+			}
+			arr, ok := args[0].(*Array)
+			if !ok {
+				return newError("argument to `join` must be ARRAY, got %s",
+					args[0].Type())
+			}
+			sep, ok := args[1].(*String)
+			if !ok {
+				return newError("argument to `join` must be STRING, got %s",
+					args[1].Type())
+			}
+
+			parts := make([]string, len(arr.Elements))
+			for i, e := range arr.Elements {
+				parts[i] = ToString(e)
+			}
+
+			return &String{Value: strings.Join(parts, sep.Value)}
+		},
+		},
+	},
+	{
+		"trim",
+		&Builtin{Fn: func(args ...Object) Object {
+			strs, err := stringArgs("trim", args, 1)
+			if err != nil {
+				return err
+			}
+
+			return &String{Value: strings.TrimSpace(strs[0])}
+		},
+		},
+	},
+	{
+		"upper",
+		&Builtin{Fn: func(args ...Object) Object {
+			strs, err := stringArgs("upper", args, 1)
+			if err != nil {
+				return err
+			}
+
+			return &String{Value: strings.ToUpper(strs[0])}
+		},
+		},
+	},
+	{
+		"lower",
+		&Builtin{Fn: func(args ...Object) Object {
+			strs, err := stringArgs("lower", args, 1)
+			if err != nil {
+				return err
+			}
+
+			return &String{Value: strings.ToLower(strs[0])}
+		},
+		},
+	},
+	{
+		"contains",
+		&Builtin{Fn: func(args ...Object) Object {
+			strs, err := stringArgs("contains", args, 2)
+			if err != nil {
+				return err
+			}
+
+			return NativeBool(strings.Contains(strs[0], strs[1]))
+		},
+		},
+	},
+	{
+		"starts_with",
+		&Builtin{Fn: func(args ...Object) Object {
+			strs, err := stringArgs("starts_with", args, 2)
+			if err != nil {
+				return err
+			}
+
+			return NativeBool(strings.HasPrefix(strs[0], strs[1]))
+		},
+		},
+	},
+	{
+		"ends_with",
+		&Builtin{Fn: func(args ...Object) Object {
+			strs, err := stringArgs("ends_with", args, 2)
+			if err != nil {
+				return err
+			}
+
+			return NativeBool(strings.HasSuffix(strs[0], strs[1]))
+		},
+		},
+	},
+	{
+		"replace",
+		&Builtin{Fn: func(args ...Object) Object {
+			strs, err := stringArgs("replace", args, 3)
+			if err != nil {
+				return err
+			}
+
+			return &String{Value: strings.ReplaceAll(strs[0], strs[1], strs[2])}
+		},
+		},
+	},
+	{
+		"index_of",
+		&Builtin{Fn: func(args ...Object) Object {
+			strs, err := stringArgs("index_of", args, 2)
+			if err != nil {
+				return err
+			}
+
+			// A byte offset, like the lengths returned by len, or -1.
+			return &Integer{Value: int64(strings.Index(strs[0], strs[1]))}
+		},
+		},
+	},
+	{
+		"repeat",
+		&Builtin{Fn: func(args ...Object) Object {
+			if len(args) != 2 {
+				return newError("wrong number of arguments. got=%d, want=2",
+					len(args))
+			}
+			str, ok := args[0].(*String)
+			if !ok {
+				return newError("argument to `repeat` must be STRING, got %s",
+					args[0].Type())
+			}
+			count, ok := args[1].(*Integer)
+			if !ok {
+				return newError("argument to `repeat` must be INTEGER, got %s",
+					args[1].Type())
+			}
+			if count.Value < 0 {
+				return newError("argument to `repeat` must not be negative, got %d",
+					count.Value)
+			}
+			if len(str.Value) > 0 && count.Value > maxStringLength/int64(len(str.Value)) {
+				return newError("result of `repeat` would exceed %d bytes", maxStringLength)
+			}
+
+			return &String{Value: strings.Repeat(str.Value, int(count.Value))}
+		},
+		},
+	},
+	{
+		"chars",
+		&Builtin{Fn: func(args ...Object) Object {
+			strs, err := stringArgs("chars", args, 1)
+			if err != nil {
+				return err
+			}
+
+			chars := []string{}
+			for _, r := range strs[0] {
+				chars = append(chars, string(r))
+			}
+
+			return stringArray(chars)
+		},
+		},
+	},
+}
+
+// stringArgs checks that args are count strings for the builtin name and
+// returns their values.
+func stringArgs(name string, args []Object, count int) ([]string, *Error) {
+	if len(args) != count {
+		return nil, newError("wrong number of arguments. got=%d, want=%d",
+			len(args), count)
+	}
+
+	strs := make([]string, count)
+	for i, arg := range args {
+		str, ok := arg.(*String)
+		if !ok {
+			return nil, newError("argument to `%s` must be STRING, got %s",
+				name, arg.Type())
+		}
+		strs[i] = str.Value
+	}
+
+	return strs, nil
+}
+
+func stringArray(strs []string) *Array {
+	elements := make([]Object, len(strs))
+	for i, s := range strs {
+		elements[i] = &String{Value: s}
+	}
+	return &Array{Elements: elements}
+}
@@ -0,0 +1,82 @@
+package object
+
+import "strconv"
+
+var typeBuiltins = []BuiltinDefinition{
+	{
+		"type",
+		&Builtin{Fn: func(args ...Object) Object {
+			if len(args) != 1 {
+				return newError("wrong number of arguments. got=%d, want=1",
+					len(args))
+			}
+
+			return &String{Value: string(typeName(args[0]))}
+		},
+		},
+	},
+	{
+		"str",
+		&Builtin{Fn: func(args ...Object) Object {
+			if len(args) != 1 {
+				return newError("wrong number of arguments. got=%d, want=1",
+					len(args))
+			}
+
+			return &String{Value: ToString(args[0])}
+		},
+		},
+	},
+	{
+		"int",
+		&Builtin{Fn: func(args ...Object) Object {
+			if len(args) != 1 {
+				return newError("wrong number of arguments. got=%d, want=1",
+					len(args))
+			}
+
+			switch arg := args[0].(type) {
+			case *Integer:
+				return arg
+			case *Boolean:
+				if arg.Value {
+					return &Integer{Value: 1}
+				}
+				return &Integer{Value: 0}
+			case *String:
+				value, err := strconv.ParseInt(arg.Value, 10, 64)
+				if err != nil {
+					return newError("could not parse %q as integer", arg.Value)
+				}
+				return &Integer{Value: value}
+			default:
+				return newError("argument to `int` not supported, got %s",
+					args[0].Type())
+			}
+		},
+		},
+	},
+	{
+		"bool",
+		&Builtin{Fn: func(args ...Object) Object {
+			if len(args) != 1 {
+				return newError("wrong number of arguments. got=%d, want=1",
+					len(args))
+			}
+
+			return NativeBool(isTruthy(args[0]))
+		},
+		},
+	},
+}
+
+// typeName returns the type of obj as reported by `type`. Functions have
+// the same type in both engines.
+func typeName(obj Object) ObjectType {
+	switch obj.Type() {
+	case CLOSURE_OBJ, COMPILED_FUNCTION_OBJ:
+		return FUNCTION_OBJ
+	default:
+		return obj.Type()
+	}
+}
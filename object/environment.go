@@ -1,19 +1,50 @@
 package object
 
+import "fmt"
+
 func NewEnclosedEnvironment(outer *Environment) *Environment {
 	env := NewEnvironment()
 	env.outer = outer
 	return env
 }
 
+// NewFunctionEnvironment returns the environment of a call to a function
+// defined in outer. Variables of enclosing functions are captured by the
+// function and cannot be assigned in it.
+func NewFunctionEnvironment(outer *Environment) *Environment {
+	env := NewEnclosedEnvironment(outer)
+	env.function = true
+	return env
+}
+
 func NewEnvironment() *Environment {
 	s := make(map[string]Object)
-	return &Environment{store: s, outer: nil}
+	c := make(map[string]bool)
+	return &Environment{store: s, constants: c, outer: nil}
 }
 
 type Environment struct {
-	store map[string]Object
-	outer *Environment
+	store     map[string]Object
+	constants map[string]bool
+	outer     *Environment
+	runtime   *Runtime
+	function  bool            // set by NewFunctionEnvironment
+	captured  map[string]bool // set by Capture
+}
+
+// SetRuntime sets the runtime of the programs evaluated in e and in the
+// environments it encloses.
+func (e *Environment) SetRuntime(runtime *Runtime) { e.runtime = runtime }
+
+// Runtime returns the runtime of the nearest environment that has one, or
+// DefaultRuntime.
+func (e *Environment) Runtime() *Runtime {
+	for env := e; env != nil; env = env.outer {
+		if env.runtime != nil {
+			return env.runtime
+		}
+	}
+	return DefaultRuntime
 }
 
 func (e *Environment) Get(name string) (Object, bool) {
@@ -28,3 +59,88 @@ func (e *Environment) Set(name string, val Object) Object {
 	e.store[name] = val
 	return val
 }
+
+// Define binds name in this environment, as a constant if constant is true.
+// It fails when name is already a constant of this environment; constants
+// of enclosing environments may be shadowed.
+func (e *Environment) Define(name string, val Object, constant bool) error {
+	if e.constants[name] {
+		return fmt.Errorf("cannot redeclare constant %s", name)
+	}
+	if _, ok := e.store[name]; e.captured[name] && (!ok || e.inFunction()) {
+		return fmt.Errorf("cannot redeclare captured variable %s", name)
+	}
+
+	e.store[name] = val
+	if constant {
+		e.constants[name] = true
+	}
+	return nil
+}
+
+// Assign rebinds the innermost existing binding of name. It fails when
+// there is no such binding, when it is a constant, when it belongs to a
+// function enclosing the one assigning to it or when it has been captured.
+func (e *Environment) Assign(name string, val Object) error {
+	return e.assign(name, val, false)
+}
+
+// assign implements Assign; crossed reports whether a function boundary
+// lies between e and the environment the assignment started in.
+func (e *Environment) assign(name string, val Object, crossed bool) error {
+	if _, ok := e.store[name]; ok {
+		if e.constants[name] {
+			return fmt.Errorf("cannot assign to constant %s", name)
+		}
+		if (e.captured[name] || crossed) && e.inFunction() {
+			return fmt.Errorf("cannot assign to captured variable %s", name)
+		}
+		e.store[name] = val
+		return nil
+	}
+
+	if e.outer == nil {
+		return fmt.Errorf("identifier not found: %s", name)
+	}
+
+	return e.outer.assign(name, val, crossed || e.function)
+}
+
+// Capture records that a closure created in e refers to the innermost
+// binding of name. Compiled closures keep a copy of the variables of
+// enclosing functions and a fixed slot for the others, so name can no
+// longer be redeclared between e and the binding, nor the binding be
+// assigned or redeclared if it belongs to a function call.
+func (e *Environment) Capture(name string) {
+	var binding *Environment
+	for env := e; env != nil && binding == nil; env = env.outer {
+		if _, ok := env.store[name]; ok {
+			binding = env
+		}
+	}
+	if binding == nil {
+		return
+	}
+
+	for env := e; ; env = env.outer {
+		if env.captured == nil {
+			env.captured = make(map[string]bool)
+		}
+		env.captured[name] = true
+
+		if env == binding {
+			return
+		}
+	}
+}
+
+// inFunction reports whether e belongs to a function call rather than to
+// the top level of a program or module.
+func (e *Environment) inFunction() bool {
+	for env := e; env != nil; env = env.outer {
+		if env.function {
+			return true
+		}
+	}
+	return false
+}
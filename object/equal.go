@@ -0,0 +1,109 @@
+package object
+
+import (
+	"encoding/binary"
+	"hash/fnv"
+)
+
+// Equal reports whether a and b are the same value. Integers, booleans and
+// strings are compared by value, arrays and hashes by their contents, and
+// all other objects by identity. Values that contain themselves compare
+// without looping forever.
+func Equal(a, b Object) bool {
+	return equal(a, b, map[objectPair]bool{})
+}
+
+type objectPair struct {
+	a, b Object
+}
+
+func equal(a, b Object, seen map[objectPair]bool) bool {
+	switch a := a.(type) {
+	case *Integer:
+		b, ok := b.(*Integer)
+		return ok && a.Value == b.Value
+
+	case *Boolean:
+		b, ok := b.(*Boolean)
+		return ok && a.Value == b.Value
+
+	case *String:
+		b, ok := b.(*String)
+		return ok && a.Value == b.Value
+
+	case *Null:
+		_, ok := b.(*Null)
+		return ok
+
+	case *Array:
+		b, ok := b.(*Array)
+		if !ok || len(a.Elements) != len(b.Elements) {
+			return false
+		}
+		if a == b || seen[objectPair{a, b}] {
+			return true
+		}
+		seen[objectPair{a, b}] = true
+
+		for i := range a.Elements {
+			if !equal(a.Elements[i], b.Elements[i], seen) {
+				return false
+			}
+		}
+		return true
+
+	case *Hash:
+		b, ok := b.(*Hash)
+		if !ok || a.Len() != b.Len() {
+			return false
+		}
+		if a == b || seen[objectPair{a, b}] {
+			return true
+		}
+		seen[objectPair{a, b}] = true
+
+		for _, pair := range a.pairs {
+			value, ok := b.Get(pair.Key.(Hashable))
+			if !ok || !equal(pair.Value, value, seen) {
+				return false
+			}
+		}
+		return true
+
+	default:
+		return a == b
+	}
+}
+
+// AsHashable returns obj as a hash key if it can be one: an integer, a
+// boolean, a string or an array of such keys.
+func AsHashable(obj Object) (Hashable, bool) {
+	if array, ok := obj.(*Array); ok {
+		for _, e := range array.Elements {
+			if _, ok := AsHashable(e); !ok {
+				return nil, false
+			}
+		}
+		return array, true
+	}
+
+	key, ok := obj.(Hashable)
+	return key, ok
+}
+
+// HashKey combines the hash keys of the elements, which must all be
+// usable as hash keys; see AsHashable. Arrays cannot be changed once
+// created, so an array keeps its HashKey.
+func (ao *Array) HashKey() HashKey {
+	h := fnv.New64a()
+
+	var buf [8]byte
+	for _, e := range ao.Elements {
+		key := e.(Hashable).HashKey()
+		h.Write([]byte(key.Type))
+		binary.LittleEndian.PutUint64(buf[:], key.Value)
+		h.Write(buf[:])
+	}
+
+	return HashKey{Type: ao.Type(), Value: h.Sum64()}
+}
@@ -5,11 +5,15 @@ import (
 	"fmt"
 	"hash/fnv"
 	"monkey/ast"
+	"monkey/code"
 	"strings"
 )
 
 type BuiltinFunction func(args ...Object) Object
 
+// ContextFunction is a builtin that needs the Context it is called in.
+type ContextFunction func(ctx *Context, args ...Object) Object
+
 type ObjectType string
 
 const (
@@ -22,11 +26,17 @@ const (
 
 	RETURN_VALUE_OBJ = "RETURN_VALUE"
 
-	FUNCTION_OBJ = "FUNCTION"
-	BUILTIN_OBJ  = "BUILTIN"
+	FUNCTION_OBJ          = "FUNCTION"
+	BUILTIN_OBJ           = "BUILTIN"
+	COMPILED_FUNCTION_OBJ = "COMPILED_FUNCTION_OBJ"
+	CLOSURE_OBJ           = "CLOSURE"
+
+	ARRAY_OBJ  = "ARRAY"
+	HASH_OBJ   = "HASH"
+	MODULE_OBJ = "MODULE"
 
-	ARRAY_OBJ = "ARRAY"
-	HASH_OBJ  = "HASH"
+	QUOTE_OBJ = "QUOTE"
+	MACRO_OBJ = "MACRO"
 )
 
 type HashKey struct {
@@ -35,12 +45,27 @@ type HashKey struct {
 }
 
 type Hashable interface {
+	Object
 	HashKey() HashKey
 }
 
+// Object is a Monkey value. Inspect returns its display form, as printed
+// by puts, and Repr returns it as a Monkey literal, as printed by the
+// REPL. Values that have no literal form use their display form as Repr.
 type Object interface {
 	Type() ObjectType
 	Inspect() string
+	Repr() string
+}
+
+// ToString converts obj to the text used when it is embedded in a string,
+// e.g. by "${...}" placeholders. Strings convert to their raw value and
+// everything else to its Inspect form.
+func ToString(obj Object) string {
+	if str, ok := obj.(*String); ok {
+		return str.Value
+	}
+	return obj.Inspect()
 }
 
 type Integer struct {
@@ -49,6 +74,7 @@ type Integer struct {
 
 func (i *Integer) Type() ObjectType { return INTEGER_OBJ }
 func (i *Integer) Inspect() string  { return fmt.Sprintf("%d", i.Value) }
+func (i *Integer) Repr() string     { return i.Inspect() }
 func (i *Integer) HashKey() HashKey {
 	return HashKey{Type: i.Type(), Value: uint64(i.Value)}
 }
@@ -59,6 +85,7 @@ type Boolean struct {
 
 func (b *Boolean) Type() ObjectType { return BOOLEAN_OBJ }
 func (b *Boolean) Inspect() string  { return fmt.Sprintf("%t", b.Value) }
+func (b *Boolean) Repr() string     { return b.Inspect() }
 func (b *Boolean) HashKey() HashKey {
 	var value uint64
 
@@ -75,6 +102,7 @@ type Null struct{}
 
 func (n *Null) Type() ObjectType { return NULL_OBJ }
 func (n *Null) Inspect() string  { return "null" }
+func (n *Null) Repr() string     { return n.Inspect() }
 
 type ReturnValue struct {
 	Value Object
@@ -82,16 +110,70 @@ type ReturnValue struct {
 
 func (rv *ReturnValue) Type() ObjectType { return RETURN_VALUE_OBJ }
 func (rv *ReturnValue) Inspect() string  { return rv.Value.Inspect() }
+func (rv *ReturnValue) Repr() string     { return rv.Value.Repr() }
 
+// Error unwinds evaluation until a try statement catches it or it
+// reaches the top level.
 type Error struct {
 	Message string
+	Kind    string // RUNTIME_ERROR_KIND if empty
+	Line    int    // line of the statement that raised it, 0 until known
+	Value   *Hash  // the error hash thrown by `throw`, if any
 }
 
 func (e *Error) Type() ObjectType { return ERROR_OBJ }
 func (e *Error) Inspect() string  { return "ERROR: " + e.Message }
+func (e *Error) Repr() string     { return e.Inspect() }
+
+// Kinds of the errors raised by the engines and by throwing a value that
+// is not already an error hash. Builtins raise PERMISSION_ERROR_KIND
+// errors when the host has not allowed what they were asked to do.
+const (
+	RUNTIME_ERROR_KIND    = "RuntimeError"
+	THROWN_ERROR_KIND     = "Error"
+	PERMISSION_ERROR_KIND = "PermissionError"
+)
+
+// NewErrorHash returns the hash-like value bound by a catch clause, with
+// the keys "message", "kind" and "location".
+func NewErrorHash(message, kind string, line int) *Hash {
+	location := "unknown"
+	if line > 0 {
+		location = fmt.Sprintf("line %d", line)
+	}
+
+	h := NewHash()
+	for _, kv := range [][2]string{
+		{"message", message},
+		{"kind", kind},
+		{"location", location},
+	} {
+		h.Set(&String{Value: kv[0]}, &String{Value: kv[1]})
+	}
+	return h
+}
+
+// ThrownError returns the error hash raised by throwing val on line: val
+// itself if it is a hash, otherwise a new error hash describing it.
+func ThrownError(val Object, line int) *Hash {
+	if h, ok := val.(*Hash); ok {
+		return h
+	}
+	return NewErrorHash(ToString(val), THROWN_ERROR_KIND, line)
+}
+
+// ErrorMessage returns the message reported for an uncaught error hash.
+func ErrorMessage(h *Hash) string {
+	if message, ok := h.Get(&String{Value: "message"}); ok {
+		return ToString(message)
+	}
+	return h.Inspect()
+}
 
 type Function struct {
 	Parameters []*ast.Identifier
+	Defaults   map[string]ast.Expression
+	Rest       *ast.Identifier
 	Body       *ast.BlockStatement
 	Env        *Environment
 }
@@ -100,40 +182,187 @@ func (f *Function) Type() ObjectType { return FUNCTION_OBJ }
 func (f *Function) Inspect() string {
 	var out bytes.Buffer
 
+	params := ast.ParameterList(f.Parameters, f.Defaults, f.Rest)
+
+	out.WriteString("fn")
+	out.WriteString("(")
+	out.WriteString(strings.Join(params, ", "))
+	out.WriteString(") {\n")
+	out.WriteString(f.Body.String())
+	out.WriteString("\n}")
+
+	return out.String()
+}
+func (f *Function) Repr() string { return f.Inspect() }
+
+// Quote is an unevaluated piece of source code, as returned by quote.
+type Quote struct {
+	Node ast.Node
+}
+
+func (q *Quote) Type() ObjectType { return QUOTE_OBJ }
+func (q *Quote) Inspect() string {
+	return "QUOTE(" + q.Node.String() + ")"
+}
+func (q *Quote) Repr() string { return q.Inspect() }
+
+type Macro struct {
+	Parameters []*ast.Identifier
+	Body       *ast.BlockStatement
+	Env        *Environment
+}
+
+func (m *Macro) Type() ObjectType { return MACRO_OBJ }
+func (m *Macro) Inspect() string {
+	var out bytes.Buffer
+
 	params := []string{}
-	for _, p := range f.Parameters {
+	for _, p := range m.Parameters {
 		params = append(params, p.String())
 	}
 
-	out.WriteString("fn")
+	out.WriteString("macro")
 	out.WriteString("(")
 	out.WriteString(strings.Join(params, ", "))
 	out.WriteString(") {\n")
-	out.WriteString(f.Body.String())
+	out.WriteString(m.Body.String())
 	out.WriteString("\n}")
 
 	return out.String()
 }
+func (m *Macro) Repr() string { return m.Inspect() }
+
+// Module is an imported file. Its exports are looked up in Env when they
+// are accessed, so they reflect later assignments made by the module.
+type Module struct {
+	Name    string
+	Env     *Environment
+	Exports map[string]bool
+}
+
+func (m *Module) Type() ObjectType { return MODULE_OBJ }
+func (m *Module) Inspect() string  { return fmt.Sprintf("<module %s>", m.Name) }
+func (m *Module) Repr() string     { return m.Inspect() }
+
+// Get returns the value of the export name.
+func (m *Module) Get(name string) (Object, bool) {
+	if !m.Exports[name] {
+		return nil, false
+	}
+	return m.Env.Get(name)
+}
+
+// WrongArgumentCount formats the error reported by both engines when a
+// function is called with an argument count outside [min, max]. A negative
+// max means the function takes any number of extra arguments.
+func WrongArgumentCount(got, min, max int) string {
+	switch {
+	case max < 0:
+		return fmt.Sprintf("wrong number of arguments. got=%d, want at least %d",
+			got, min)
+	case min == max:
+		return fmt.Sprintf("wrong number of arguments. got=%d, want=%d", got, min)
+	default:
+		return fmt.Sprintf("wrong number of arguments. got=%d, want=%d..%d",
+			got, min, max)
+	}
+}
+
+type CompiledFunction struct {
+	Instructions  code.Instructions
+	NumLocals     int
+	NumParameters int  // named parameters, not counting the rest parameter
+	NumRequired   int  // parameters without a default value
+	Variadic      bool // the rest parameter lives in local slot NumParameters
+	Lines         code.LineTable
+}
+
+func (cf *CompiledFunction) Type() ObjectType { return COMPILED_FUNCTION_OBJ }
+func (cf *CompiledFunction) Inspect() string {
+	return fmt.Sprintf("CompiledFunction[%p]", cf)
+}
+func (cf *CompiledFunction) Repr() string { return cf.Inspect() }
 
+type Closure struct {
+	Fn   *CompiledFunction
+	Free []Object
+}
+
+func (c *Closure) Type() ObjectType { return CLOSURE_OBJ }
+func (c *Closure) Inspect() string {
+	return fmt.Sprintf("Closure[%p]", c)
+}
+func (c *Closure) Repr() string { return c.Inspect() }
+
+// String values must not be changed once the string is created, since
+// its hash is computed only once.
 type String struct {
 	Value string
+
+	hash   uint64
+	hashed bool
 }
 
 func (s *String) Type() ObjectType { return STRING_OBJ }
 func (s *String) Inspect() string  { return s.Value }
+func (s *String) Repr() string {
+	var out strings.Builder
+
+	out.WriteByte('"')
+	for i := 0; i < len(s.Value); i++ {
+		switch c := s.Value[i]; c {
+		case '\\', '"':
+			out.WriteByte('\\')
+			out.WriteByte(c)
+		case '\n':
+			out.WriteString(`\n`)
+		case '\r':
+			out.WriteString(`\r`)
+		case '\t':
+			out.WriteString(`\t`)
+		case '$':
+			// Only "${" would start a placeholder.
+			if i+1 < len(s.Value) && s.Value[i+1] == '{' {
+				out.WriteByte('\\')
+			}
+			out.WriteByte(c)
+		default:
+			out.WriteByte(c)
+		}
+	}
+	out.WriteByte('"')
+
+	return out.String()
+}
 func (s *String) HashKey() HashKey {
-	h := fnv.New64a()
-	h.Write([]byte(s.Value))
+	if !s.hashed {
+		h := fnv.New64a()
+		h.Write([]byte(s.Value))
+		s.hash = h.Sum64()
+		s.hashed = true
+	}
 
-	return HashKey{Type: s.Type(), Value: h.Sum64()}
+	return HashKey{Type: s.Type(), Value: s.hash}
 }
 
+// Builtin is a function implemented in Go. Exactly one of Fn and
+// WithContext is set.
 type Builtin struct {
-	Fn BuiltinFunction
+	Fn          BuiltinFunction
+	WithContext ContextFunction
 }
 
 func (b *Builtin) Type() ObjectType { return BUILTIN_OBJ }
 func (b *Builtin) Inspect() string  { return "builtin function" }
+func (b *Builtin) Repr() string     { return b.Inspect() }
+
+// Call calls the builtin with args in ctx.
+func (b *Builtin) Call(ctx *Context, args ...Object) Object {
+	if b.WithContext != nil {
+		return b.WithContext(ctx, args...)
+	}
+	return b.Fn(args...)
+}
 
 type Array struct {
 	Elements []Object
@@ -154,14 +383,70 @@ func (ao *Array) Inspect() string {
 
 	return out.String()
 }
+func (ao *Array) Repr() string {
+	elements := []string{}
+	for _, e := range ao.Elements {
+		elements = append(elements, e.Repr())
+	}
+
+	return "[" + strings.Join(elements, ", ") + "]"
+}
 
 type HashPair struct {
 	Key   Object
 	Value Object
 }
 
+// Hash maps keys to values and remembers the order in which keys were
+// first added. Keys are found by their HashKey and then compared with
+// Equal, so keys whose HashKeys collide are kept apart.
 type Hash struct {
-	Pairs map[HashKey]HashPair
+	pairs   []HashPair        // in insertion order
+	buckets map[HashKey][]int // indices into pairs of the keys with a HashKey
+}
+
+func NewHash() *Hash {
+	return &Hash{buckets: make(map[HashKey][]int)}
+}
+
+// Get returns the value stored under key.
+func (h *Hash) Get(key Hashable) (Object, bool) {
+	if i, ok := h.index(key); ok {
+		return h.pairs[i].Value, true
+	}
+	return nil, false
+}
+
+// Set stores value under key. A key that is already present keeps its
+// position.
+func (h *Hash) Set(key Hashable, value Object) {
+	if i, ok := h.index(key); ok {
+		h.pairs[i].Value = value
+		return
+	}
+
+	hashKey := key.HashKey()
+	h.buckets[hashKey] = append(h.buckets[hashKey], len(h.pairs))
+	h.pairs = append(h.pairs, HashPair{Key: key, Value: value})
+}
+
+// Len returns the number of pairs in h.
+func (h *Hash) Len() int { return len(h.pairs) }
+
+// Ordered returns the pairs of h in insertion order.
+func (h *Hash) Ordered() []HashPair {
+	pairs := make([]HashPair, len(h.pairs))
+	copy(pairs, h.pairs)
+	return pairs
+}
+
+func (h *Hash) index(key Hashable) (int, bool) {
+	for _, i := range h.buckets[key.HashKey()] {
+		if Equal(h.pairs[i].Key, key) {
+			return i, true
+		}
+	}
+	return 0, false
 }
 
 func (h *Hash) Type() ObjectType { return HASH_OBJ }
@@ -169,7 +454,7 @@ func (h *Hash) Inspect() string {
 	var out bytes.Buffer
 
 	pairs := []string{}
-	for _, pair := range h.Pairs {
+	for _, pair := range h.Ordered() {
 		pairs = append(pairs, fmt.Sprintf("%s: %s",
 			pair.Key.Inspect(), pair.Value.Inspect()))
 	}
@@ -180,3 +465,11 @@ func (h *Hash) Inspect() string {
 
 	return out.String()
 }
+func (h *Hash) Repr() string {
+	pairs := []string{}
+	for _, pair := range h.Ordered() {
+		pairs = append(pairs, pair.Key.Repr()+": "+pair.Value.Repr())
+	}
+
+	return "{" + strings.Join(pairs, ", ") + "}"
+}
@@ -1,6 +1,10 @@
 package object
 
-import "testing"
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
 
 func TestStringHashKey(t *testing.T) {
 	hello1 := &String{Value: "Hello World"}
@@ -58,3 +62,324 @@ func TestIntegerHashKey(t *testing.T) {
 		t.Errorf("integers with twoerent content have same hash keys")
 	}
 }
+
+func TestHashKeepsInsertionOrder(t *testing.T) {
+	h := NewHash()
+	for _, kv := range []struct {
+		key   string
+		value int64
+	}{
+		{"z", 1},
+		{"a", 2},
+		{"m", 3},
+		{"z", 4},
+	} {
+		h.Set(&String{Value: kv.key}, &Integer{Value: kv.value})
+	}
+
+	if h.Len() != 3 {
+		t.Fatalf("wrong number of pairs. got=%d", h.Len())
+	}
+
+	expected := "{z: 4, a: 2, m: 3}"
+	if h.Inspect() != expected {
+		t.Errorf("h.Inspect() wrong. want=%q, got=%q", expected, h.Inspect())
+	}
+}
+
+func TestRepr(t *testing.T) {
+	h := NewHash()
+	h.Set(&String{Value: "name"}, &String{Value: "Monkey"})
+	h.Set(&Integer{Value: 1}, &Array{Elements: []Object{
+		&Boolean{Value: true}, &Null{}, &String{Value: "a\"b"},
+	}})
+
+	tests := []struct {
+		obj          Object
+		expectedRepr string
+	}{
+		{&Integer{Value: -5}, "-5"},
+		{&Boolean{Value: false}, "false"},
+		{&Null{}, "null"},
+		{&String{Value: "plain"}, `"plain"`},
+		{&String{Value: "say \"hi\"\n"}, `"say \"hi\"\n"`},
+		{&String{Value: "a\\b\tc\r"}, `"a\\b\tc\r"`},
+		{&String{Value: "${x} costs $5"}, `"\${x} costs $5"`},
+		{&Array{Elements: []Object{&String{Value: "a"}, &Integer{Value: 1}}}, `["a", 1]`},
+		{h, `{"name": "Monkey", 1: [true, null, "a\"b"]}`},
+	}
+
+	for _, tt := range tests {
+		if got := tt.obj.Repr(); got != tt.expectedRepr {
+			t.Errorf("%s.Repr() wrong. want=%s, got=%s",
+				tt.obj.Type(), tt.expectedRepr, got)
+		}
+	}
+
+	if h.Inspect() != `{name: Monkey, 1: [true, null, a"b]}` {
+		t.Errorf("h.Inspect() wrong. got=%s", h.Inspect())
+	}
+}
+
+// collidingKey is a hash key whose HashKey is the same for every value.
+type collidingKey struct{ name string }
+
+func (c *collidingKey) Type() ObjectType { return "COLLIDING" }
+func (c *collidingKey) Inspect() string  { return c.name }
+func (c *collidingKey) Repr() string     { return c.name }
+func (c *collidingKey) HashKey() HashKey { return HashKey{Type: "COLLIDING", Value: 42} }
+
+func TestHashKeepsCollidingKeysApart(t *testing.T) {
+	a := &collidingKey{name: "a"}
+	b := &collidingKey{name: "b"}
+
+	h := NewHash()
+	h.Set(a, &Integer{Value: 1})
+	h.Set(b, &Integer{Value: 2})
+	h.Set(a, &Integer{Value: 3})
+
+	if h.Len() != 2 {
+		t.Fatalf("colliding keys overwrote each other. got=%d pairs", h.Len())
+	}
+
+	for key, want := range map[Hashable]int64{a: 3, b: 2} {
+		value, ok := h.Get(key)
+		if !ok {
+			t.Errorf("no value for key %s", key.Inspect())
+			continue
+		}
+		if value.(*Integer).Value != want {
+			t.Errorf("wrong value for key %s. want=%d, got=%d",
+				key.Inspect(), want, value.(*Integer).Value)
+		}
+	}
+
+	if _, ok := h.Get(&collidingKey{name: "c"}); ok {
+		t.Errorf("found a value for a key that was never set")
+	}
+}
+
+func TestHashKeysCompareByValue(t *testing.T) {
+	h := NewHash()
+	h.Set(&String{Value: "1"}, &Integer{Value: 1})
+	h.Set(&Integer{Value: 1}, &Integer{Value: 2})
+	h.Set(&Boolean{Value: true}, &Integer{Value: 3})
+
+	if h.Len() != 3 {
+		t.Fatalf("keys of different types were merged. got=%d pairs", h.Len())
+	}
+
+	value, ok := h.Get(&String{Value: "1"})
+	if !ok || value.(*Integer).Value != 1 {
+		t.Errorf("string key not found by an equal string. got=%v", value)
+	}
+}
+
+func TestStringHashKeyIsCached(t *testing.T) {
+	s := &String{Value: "cached"}
+	first := s.HashKey()
+
+	if !s.hashed || s.hash != first.Value {
+		t.Fatalf("hash was not cached")
+	}
+
+	if s.HashKey() != first {
+		t.Errorf("cached hash key differs from the first one")
+	}
+}
+
+func TestEqual(t *testing.T) {
+	hash := func(kv ...Object) *Hash {
+		h := NewHash()
+		for i := 0; i < len(kv); i += 2 {
+			h.Set(kv[i].(Hashable), kv[i+1])
+		}
+		return h
+	}
+	array := func(elements ...Object) *Array { return &Array{Elements: elements} }
+	i := func(v int64) *Integer { return &Integer{Value: v} }
+	s := func(v string) *String { return &String{Value: v} }
+
+	fn := &Builtin{}
+
+	tests := []struct {
+		a, b     Object
+		expected bool
+	}{
+		{i(1), i(1), true},
+		{i(1), i(2), false},
+		{s("a"), s("a"), true},
+		{s("1"), i(1), false},
+		{&Null{}, &Null{}, true},
+		{array(i(1), s("a")), array(i(1), s("a")), true},
+		{array(i(1), array(i(2))), array(i(1), array(i(2))), true},
+		{array(i(1), i(2)), array(i(2), i(1)), false},
+		{array(i(1)), array(i(1), i(1)), false},
+		{hash(s("a"), i(1), s("b"), i(2)), hash(s("b"), i(2), s("a"), i(1)), true},
+		{hash(s("a"), array(i(1))), hash(s("a"), array(i(1))), true},
+		{hash(s("a"), i(1)), hash(s("a"), i(2)), false},
+		{hash(s("a"), i(1)), hash(s("b"), i(1)), false},
+		{array(), hash(), false},
+		{fn, fn, true},
+		{fn, &Builtin{}, false},
+	}
+
+	for _, tt := range tests {
+		if got := Equal(tt.a, tt.b); got != tt.expected {
+			t.Errorf("Equal(%s, %s) wrong. want=%t, got=%t",
+				tt.a.Inspect(), tt.b.Inspect(), tt.expected, got)
+		}
+	}
+}
+
+func TestEqualTerminatesOnCycles(t *testing.T) {
+	a := &Array{}
+	a.Elements = []Object{&Integer{Value: 1}, a}
+	b := &Array{}
+	b.Elements = []Object{&Integer{Value: 1}, b}
+
+	if !Equal(a, b) {
+		t.Errorf("equal cyclic arrays compared unequal")
+	}
+
+	c := &Array{}
+	c.Elements = []Object{&Integer{Value: 2}, c}
+	if Equal(a, c) {
+		t.Errorf("different cyclic arrays compared equal")
+	}
+
+	h1 := NewHash()
+	h1.Set(&String{Value: "self"}, h1)
+	h2 := NewHash()
+	h2.Set(&String{Value: "self"}, h2)
+	if !Equal(h1, h2) {
+		t.Errorf("equal cyclic hashes compared unequal")
+	}
+}
+
+func TestArrayHashKey(t *testing.T) {
+	a := &Array{Elements: []Object{&Integer{Value: 1}, &String{Value: "x"}}}
+	b := &Array{Elements: []Object{&Integer{Value: 1}, &String{Value: "x"}}}
+	c := &Array{Elements: []Object{&String{Value: "x"}, &Integer{Value: 1}}}
+
+	if a.HashKey() != b.HashKey() {
+		t.Errorf("arrays with same content have different hash keys")
+	}
+	if a.HashKey() == c.HashKey() {
+		t.Errorf("arrays with different content have same hash keys")
+	}
+
+	if _, ok := AsHashable(a); !ok {
+		t.Errorf("array of integers and strings is not hashable")
+	}
+	if _, ok := AsHashable(&Array{Elements: []Object{&Array{Elements: []Object{&Builtin{}}}}}); ok {
+		t.Errorf("array containing a function is hashable")
+	}
+	if _, ok := AsHashable(NewHash()); ok {
+		t.Errorf("hash is hashable")
+	}
+}
+
+func TestFileAccess(t *testing.T) {
+	dir := t.TempDir()
+	data := filepath.Join(dir, "data")
+	out := filepath.Join(dir, "out")
+	for _, d := range []string{data, out} {
+		if err := os.Mkdir(d, 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.WriteFile(filepath.Join(dir, "secret"), []byte("s"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(data, "in.txt"), []byte("a\r\nb\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink(filepath.Join(dir, "secret"), filepath.Join(data, "link")); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink(filepath.Join(dir, "created"), filepath.Join(out, "dangling")); err != nil {
+		t.Fatal(err)
+	}
+
+	runtime := NewRuntime(StdIO)
+	runtime.Files = FileAccess{Read: []string{data}, Write: []string{out}}
+
+	call := func(name string, args ...string) Object {
+		objs := []Object{}
+		for _, arg := range args {
+			objs = append(objs, &String{Value: arg})
+		}
+		return GetBuiltinByName(name).Call(&Context{Runtime: runtime}, objs...)
+	}
+
+	tests := []struct {
+		name         string
+		args         []string
+		expectedRepr string
+	}{
+		{"read_file", []string{filepath.Join(data, "in.txt")}, `"a\r\nb\n"`},
+		{"read_lines", []string{filepath.Join(data, "in.txt")}, `["a", "b"]`},
+		{"list_dir", []string{data}, `["in.txt", "link"]`},
+		{"exists", []string{filepath.Join(data, "missing")}, "false"},
+		{"write_file", []string{filepath.Join(out, "new.txt"), "x"}, "null"},
+		{"read_file", []string{filepath.Join(data, "..", "secret")}, "PermissionError"},
+		{"read_file", []string{filepath.Join(data, "link")}, "PermissionError"},
+		{"read_file", []string{filepath.Join(out, "new.txt")}, "PermissionError"},
+		{"write_file", []string{filepath.Join(data, "new.txt"), "x"}, "PermissionError"},
+		{"write_file", []string{filepath.Join(out, "dangling"), "x"}, "PermissionError"},
+	}
+
+	for _, tt := range tests {
+		result := call(tt.name, tt.args...)
+
+		var got string
+		switch result := result.(type) {
+		case nil:
+			got = "null"
+		case *Error:
+			got = result.Kind
+		default:
+			got = result.Repr()
+		}
+
+		if got != tt.expectedRepr {
+			t.Errorf("wrong result for %s(%q). want=%s, got=%s (%v)",
+				tt.name, tt.args, tt.expectedRepr, got, result)
+		}
+	}
+
+	if _, err := os.Stat(filepath.Join(dir, "created")); err == nil {
+		t.Errorf("write_file followed a dangling link out of its root")
+	}
+
+	runtime = DefaultRuntime
+	if result, ok := call("exists", data).(*Error); !ok || result.Kind != PERMISSION_ERROR_KIND {
+		t.Errorf("filesystem builtins are not disabled by default. got=%v", result)
+	}
+
+	runtime = NewRuntime(StdIO)
+	runtime.Files = FileAccess{Read: []string{""}}
+	if result, ok := call("exists", "object_test.go").(*Error); !ok || result.Kind != PERMISSION_ERROR_KIND {
+		t.Errorf("an empty root allows the working directory. got=%v", result)
+	}
+}
+
+func TestCompileRegexpCachesPatterns(t *testing.T) {
+	first, err := compileRegexp(`\d+`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, err := compileRegexp(`\d+`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if first != second {
+		t.Errorf("pattern was compiled twice")
+	}
+
+	if _, err := compileRegexp(`(`); err == nil {
+		t.Errorf("invalid pattern compiled")
+	}
+}
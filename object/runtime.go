@@ -0,0 +1,82 @@
+package object
+
+import (
+	"bufio"
+	"io"
+	"math/rand"
+	"os"
+	"time"
+)
+
+// Context is what the engine calling a builtin provides to it. A builtin
+// that needs more of its engine or host gets it from a new field here or
+// in Runtime, not from a new kind of builtin.
+type Context struct {
+	// Apply calls a function the builtin was passed, e.g. by map. It
+	// returns the function's result, or the *Error the call failed with.
+	Apply func(fn Object, args ...Object) Object
+
+	*Runtime
+}
+
+// Runtime is what the host provides to the builtins of a running
+// program, so that it can capture their effects or make them
+// reproducible.
+type Runtime struct {
+	IO    *IO        // where puts writes and input reads
+	Rand  Random     // the source of rand_int, shuffle and choice
+	Clock Clock      // the time of now and sleep
+	Files FileAccess // what read_file, write_file and the like may touch
+}
+
+// NewRuntime returns a runtime with io, a randomly seeded Random, the
+// system clock and no file access.
+func NewRuntime(io *IO) *Runtime {
+	return &Runtime{
+		IO:    io,
+		Rand:  rand.New(rand.NewSource(time.Now().UnixNano())),
+		Clock: SystemClock{},
+	}
+}
+
+// DefaultRuntime is the runtime of programs the host has given none.
+var DefaultRuntime = NewRuntime(StdIO)
+
+// IO is the output and input of a running program.
+type IO struct {
+	Out io.Writer
+	In  *bufio.Reader
+}
+
+func NewIO(in io.Reader, out io.Writer) *IO {
+	return &IO{Out: out, In: bufio.NewReader(in)}
+}
+
+// StdIO is the IO of the process.
+var StdIO = NewIO(os.Stdin, os.Stdout)
+
+// Random returns random numbers. A *rand.Rand with a fixed seed makes a
+// program's random numbers the same on every run.
+type Random interface {
+	Int63n(n int64) int64
+}
+
+// Clock tells the time and waits.
+type Clock interface {
+	Now() time.Time
+	Sleep(d time.Duration)
+}
+
+// SystemClock is the clock of the machine.
+type SystemClock struct{}
+
+func (SystemClock) Now() time.Time        { return time.Now() }
+func (SystemClock) Sleep(d time.Duration) { time.Sleep(d) }
+
+// FakeClock is a clock that only moves when it is slept on.
+type FakeClock struct {
+	Time time.Time
+}
+
+func (c *FakeClock) Now() time.Time        { return c.Time }
+func (c *FakeClock) Sleep(d time.Duration) { c.Time = c.Time.Add(d) }
@@ -6,11 +6,16 @@ import (
 	"monkey/lexer"
 	"monkey/token"
 	"strconv"
+	"strings"
 )
 
 const (
 	_ int = iota
 	LOWEST
+	ASSIGN      // x = y
+	PIPE        // x |> f(y)
+	LAMBDA      // x => x * 2
+	COALESCE    // a ?? b
 	EQUALS      // ==
 	LESSGREATER // > or <
 	SUM         // +
@@ -21,16 +26,22 @@ const (
 )
 
 var precedences = map[token.TokenType]int{
-	token.EQ:       EQUALS,
-	token.NOT_EQ:   EQUALS,
-	token.LT:       LESSGREATER,
-	token.GT:       LESSGREATER,
-	token.PLUS:     SUM,
-	token.MINUS:    SUM,
-	token.SLASH:    PRODUCT,
-	token.ASTERISK: PRODUCT,
-	token.LPAREN:   CALL,
-	token.LBRACKET: INDEX,
+	token.ASSIGN:       ASSIGN,
+	token.PIPE:         PIPE,
+	token.ARROW:        LAMBDA,
+	token.NULLISH:      COALESCE,
+	token.EQ:           EQUALS,
+	token.NOT_EQ:       EQUALS,
+	token.LT:           LESSGREATER,
+	token.GT:           LESSGREATER,
+	token.PLUS:         SUM,
+	token.MINUS:        SUM,
+	token.SLASH:        PRODUCT,
+	token.ASTERISK:     PRODUCT,
+	token.LPAREN:       CALL,
+	token.LBRACKET:     INDEX,
+	token.OPT_LBRACKET: INDEX,
+	token.DOT:          INDEX,
 }
 
 type (
@@ -47,6 +58,10 @@ type Parser struct {
 
 	prefixParseFns map[token.TokenType]prefixParseFn
 	infixParseFns  map[token.TokenType]infixParseFn
+
+	// blockDepth counts the enclosing block statements; imports and
+	// exports are only allowed at the top level.
+	blockDepth int
 }
 
 func New(l *lexer.Lexer) *Parser {
@@ -59,13 +74,17 @@ func New(l *lexer.Lexer) *Parser {
 	p.registerPrefix(token.IDENT, p.parseIdentifier)
 	p.registerPrefix(token.INT, p.parseIntegerLiteral)
 	p.registerPrefix(token.STRING, p.parseStringLiteral)
+	p.registerPrefix(token.INTERP_STRING, p.parseInterpolatedString)
+	p.registerPrefix(token.ILLEGAL, p.parseIllegal)
 	p.registerPrefix(token.BANG, p.parsePrefixExpression)
 	p.registerPrefix(token.MINUS, p.parsePrefixExpression)
 	p.registerPrefix(token.TRUE, p.parseBoolean)
 	p.registerPrefix(token.FALSE, p.parseBoolean)
+	p.registerPrefix(token.NULL, p.parseNullLiteral)
 	p.registerPrefix(token.LPAREN, p.parseGroupedExpression)
 	p.registerPrefix(token.IF, p.parseIfExpression)
 	p.registerPrefix(token.FUNCTION, p.parseFunctionLiteral)
+	p.registerPrefix(token.MACRO, p.parseMacroLiteral)
 	p.registerPrefix(token.LBRACKET, p.parseArrayLiteral)
 	p.registerPrefix(token.LBRACE, p.parseHashLiteral)
 
@@ -78,9 +97,16 @@ func New(l *lexer.Lexer) *Parser {
 	p.registerInfix(token.NOT_EQ, p.parseInfixExpression)
 	p.registerInfix(token.LT, p.parseInfixExpression)
 	p.registerInfix(token.GT, p.parseInfixExpression)
+	p.registerInfix(token.NULLISH, p.parseInfixExpression)
+
+	p.registerInfix(token.ASSIGN, p.parseAssignExpression)
+	p.registerInfix(token.PIPE, p.parsePipeExpression)
+	p.registerInfix(token.ARROW, p.parseArrowFunction)
 
 	p.registerInfix(token.LPAREN, p.parseCallExpression)
 	p.registerInfix(token.LBRACKET, p.parseIndexExpression)
+	p.registerInfix(token.OPT_LBRACKET, p.parseIndexExpression)
+	p.registerInfix(token.DOT, p.parseMemberExpression)
 
 	// Read two tokens, so curToken and peekToken are both set
 	p.nextToken()
@@ -144,10 +170,18 @@ func (p *Parser) ParseProgram() *ast.Program {
 
 func (p *Parser) parseStatement() ast.Statement {
 	switch p.curToken.Type {
-	case token.LET:
+	case token.LET, token.CONST:
 		return p.parseLetStatement()
 	case token.RETURN:
 		return p.parseReturnStatement()
+	case token.THROW:
+		return p.parseThrowStatement()
+	case token.TRY:
+		return p.parseTryStatement()
+	case token.IMPORT:
+		return p.parseImportStatement()
+	case token.EXPORT:
+		return p.parseExportStatement()
 	default:
 		return p.parseExpressionStatement()
 	}
@@ -170,6 +204,79 @@ func (p *Parser) parseLetStatement() *ast.LetStatement {
 
 	stmt.Value = p.parseExpression(LOWEST)
 
+	if fl, ok := stmt.Value.(*ast.FunctionLiteral); ok {
+		fl.Name = stmt.Name.Value
+	}
+
+	if p.peekTokenIs(token.SEMICOLON) {
+		p.nextToken()
+	}
+
+	return stmt
+}
+
+func (p *Parser) parseThrowStatement() *ast.ThrowStatement {
+	stmt := &ast.ThrowStatement{Token: p.curToken}
+
+	p.nextToken()
+
+	stmt.Value = p.parseExpression(LOWEST)
+
+	if p.peekTokenIs(token.SEMICOLON) {
+		p.nextToken()
+	}
+
+	return stmt
+}
+
+func (p *Parser) parseTryStatement() *ast.TryStatement {
+	stmt := &ast.TryStatement{Token: p.curToken}
+
+	if !p.expectPeek(token.LBRACE) {
+		return nil
+	}
+
+	stmt.Block = p.parseBlockStatement()
+
+	if p.peekTokenIs(token.CATCH) {
+		p.nextToken()
+
+		if p.peekTokenIs(token.LPAREN) {
+			p.nextToken()
+
+			if !p.expectPeek(token.IDENT) {
+				return nil
+			}
+
+			stmt.Param = &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
+
+			if !p.expectPeek(token.RPAREN) {
+				return nil
+			}
+		}
+
+		if !p.expectPeek(token.LBRACE) {
+			return nil
+		}
+
+		stmt.Catch = p.parseBlockStatement()
+	}
+
+	if p.peekTokenIs(token.FINALLY) {
+		p.nextToken()
+
+		if !p.expectPeek(token.LBRACE) {
+			return nil
+		}
+
+		stmt.Finally = p.parseBlockStatement()
+	}
+
+	if stmt.Catch == nil && stmt.Finally == nil {
+		p.errors = append(p.errors, "try without catch or finally")
+		return nil
+	}
+
 	if p.peekTokenIs(token.SEMICOLON) {
 		p.nextToken()
 	}
@@ -177,6 +284,61 @@ func (p *Parser) parseLetStatement() *ast.LetStatement {
 	return stmt
 }
 
+func (p *Parser) parseImportStatement() *ast.ImportStatement {
+	stmt := &ast.ImportStatement{Token: p.curToken}
+
+	if p.blockDepth > 0 {
+		p.errors = append(p.errors, "import is only allowed at the top level")
+		return nil
+	}
+
+	if !p.expectPeek(token.STRING) {
+		return nil
+	}
+
+	stmt.Path = &ast.StringLiteral{Token: p.curToken, Value: p.curToken.Literal}
+
+	if !p.expectPeek(token.AS) {
+		return nil
+	}
+
+	if !p.expectPeek(token.IDENT) {
+		return nil
+	}
+
+	stmt.Alias = &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
+
+	if p.peekTokenIs(token.SEMICOLON) {
+		p.nextToken()
+	}
+
+	return stmt
+}
+
+func (p *Parser) parseExportStatement() *ast.LetStatement {
+	if p.blockDepth > 0 {
+		p.errors = append(p.errors, "export is only allowed at the top level")
+		return nil
+	}
+
+	if !p.peekTokenIs(token.LET) && !p.peekTokenIs(token.CONST) {
+		msg := fmt.Sprintf("expected let or const after export, got %s instead",
+			p.peekToken.Type)
+		p.errors = append(p.errors, msg)
+		return nil
+	}
+
+	p.nextToken()
+
+	stmt := p.parseLetStatement()
+	if stmt == nil {
+		return nil
+	}
+
+	stmt.Exported = true
+	return stmt
+}
+
 func (p *Parser) parseReturnStatement() *ast.ReturnStatement {
 	stmt := &ast.ReturnStatement{Token: p.curToken}
 
@@ -260,8 +422,180 @@ func (p *Parser) parseIntegerLiteral() ast.Expression {
 	return lit
 }
 
+// parseIllegal reports a token the lexer could not make sense of. A string
+// that runs to the end of the input is lexed as an ILLEGAL token starting
+// with its opening quote.
+func (p *Parser) parseIllegal() ast.Expression {
+	if strings.HasPrefix(p.curToken.Literal, `"`) {
+		msg := fmt.Sprintf("unterminated string %s", p.curToken.Literal)
+		p.errors = append(p.errors, msg)
+		return nil
+	}
+
+	p.noPrefixParseFnError(p.curToken.Type)
+	return nil
+}
+
 func (p *Parser) parseStringLiteral() ast.Expression {
-	return &ast.StringLiteral{Token: p.curToken, Value: p.curToken.Literal}
+	return p.stringPart(p.curToken.Literal)
+}
+
+func (p *Parser) parseInterpolatedString() ast.Expression {
+	str := &ast.InterpolatedString{Token: p.curToken}
+	literal := p.curToken.Literal
+
+	text := 0
+	for i := 0; i < len(literal); i++ {
+		if literal[i] == '\\' {
+			i++
+			continue
+		}
+		if literal[i] != '$' || i+1 >= len(literal) || literal[i+1] != '{' {
+			continue
+		}
+
+		if i > text {
+			str.Parts = append(str.Parts, p.stringPart(literal[text:i]))
+		}
+
+		end := placeholderEnd(literal, i+2)
+		if end < 0 {
+			msg := fmt.Sprintf("unterminated placeholder in %q", literal)
+			p.errors = append(p.errors, msg)
+			return nil
+		}
+
+		exp := p.parsePlaceholder(literal[i+2 : end])
+		if exp == nil {
+			return nil
+		}
+		str.Parts = append(str.Parts, exp)
+
+		i = end
+		text = end + 1
+	}
+
+	if text < len(literal) {
+		str.Parts = append(str.Parts, p.stringPart(literal[text:]))
+	}
+
+	return str
+}
+
+// stringPart returns the string literal for the source text of a string
+// or of the text between the placeholders of an interpolated string.
+func (p *Parser) stringPart(text string) *ast.StringLiteral {
+	tok := token.Token{Type: token.STRING, Literal: text, Line: p.curToken.Line}
+
+	value, err := unescape(text)
+	if err != nil {
+		p.errors = append(p.errors, err.Error())
+	}
+
+	return &ast.StringLiteral{Token: tok, Value: value}
+}
+
+// unescape resolves the escape sequences \\, \", \$, \n, \r and \t in
+// the source text of a string.
+func unescape(text string) (string, error) {
+	if !strings.Contains(text, "\\") {
+		return text, nil
+	}
+
+	var out strings.Builder
+	for i := 0; i < len(text); i++ {
+		if text[i] != '\\' {
+			out.WriteByte(text[i])
+			continue
+		}
+
+		i++
+		if i == len(text) {
+			return "", fmt.Errorf("unterminated escape sequence in string")
+		}
+
+		switch text[i] {
+		case '\\', '"', '$':
+			out.WriteByte(text[i])
+		case 'n':
+			out.WriteByte('\n')
+		case 'r':
+			out.WriteByte('\r')
+		case 't':
+			out.WriteByte('\t')
+		default:
+			return "", fmt.Errorf("unknown escape sequence \\%c in string", text[i])
+		}
+	}
+
+	return out.String(), nil
+}
+
+// parsePlaceholder parses the source of a ${...} placeholder, which must
+// hold exactly one expression.
+func (p *Parser) parsePlaceholder(source string) ast.Expression {
+	sub := New(lexer.New(source))
+
+	if sub.curTokenIs(token.EOF) {
+		p.errors = append(p.errors, "empty placeholder in string")
+		return nil
+	}
+
+	exp := sub.parseExpression(LOWEST)
+	if len(sub.errors) == 0 && !sub.peekTokenIs(token.EOF) {
+		sub.peekError(token.EOF)
+	}
+
+	if len(sub.errors) > 0 {
+		for _, msg := range sub.errors {
+			p.errors = append(p.errors, "in placeholder ${"+source+"}: "+msg)
+		}
+		return nil
+	}
+
+	return exp
+}
+
+// placeholderEnd returns the index of the brace closing a placeholder whose
+// expression starts at start, skipping over nested braces and strings.
+func placeholderEnd(s string, start int) int {
+	depth := 1
+	for i := start; i < len(s); i++ {
+		switch s[i] {
+		case '{':
+			depth++
+		case '}':
+			depth--
+			if depth == 0 {
+				return i
+			}
+		case '"':
+			i = stringEnd(s, i+1)
+			if i < 0 {
+				return -1
+			}
+		}
+	}
+	return -1
+}
+
+// stringEnd returns the index of the quote closing a string that starts at
+// start, skipping over any placeholders it contains.
+func stringEnd(s string, start int) int {
+	for i := start; i < len(s); i++ {
+		switch {
+		case s[i] == '\\':
+			i++
+		case s[i] == '"':
+			return i
+		case s[i] == '$' && i+1 < len(s) && s[i+1] == '{':
+			i = placeholderEnd(s, i+2)
+			if i < 0 {
+				return -1
+			}
+		}
+	}
+	return -1
 }
 
 func (p *Parser) parsePrefixExpression() ast.Expression {
@@ -295,7 +629,132 @@ func (p *Parser) parseBoolean() ast.Expression {
 	return &ast.Boolean{Token: p.curToken, Value: p.curTokenIs(token.TRUE)}
 }
 
+// parseAssignExpression parses `name = value`. Assignment is right
+// associative, so the value is parsed at the lowest precedence.
+func (p *Parser) parseAssignExpression(left ast.Expression) ast.Expression {
+	name, ok := left.(*ast.Identifier)
+	if !ok {
+		msg := fmt.Sprintf("cannot assign to %s", left.String())
+		p.errors = append(p.errors, msg)
+		return nil
+	}
+
+	expression := &ast.AssignExpression{Token: p.curToken, Name: name}
+
+	p.nextToken()
+	expression.Value = p.parseExpression(LOWEST)
+
+	return expression
+}
+
+func (p *Parser) parsePipeExpression(left ast.Expression) ast.Expression {
+	expression := &ast.PipeExpression{Token: p.curToken, Left: left}
+
+	precedence := p.curPrecedence()
+	p.nextToken()
+	expression.Right = p.parseExpression(precedence)
+
+	return expression
+}
+
+// parseArrowFunction parses `x => body`, where left is the single
+// parameter.
+func (p *Parser) parseArrowFunction(left ast.Expression) ast.Expression {
+	param, ok := left.(*ast.Identifier)
+	if !ok {
+		msg := fmt.Sprintf("arrow function parameter must be an identifier, got %s",
+			left.String())
+		p.errors = append(p.errors, msg)
+		return nil
+	}
+
+	lit := &ast.FunctionLiteral{
+		Token:      p.curToken,
+		Parameters: []*ast.Identifier{param},
+		Defaults:   map[string]ast.Expression{},
+	}
+
+	lit.Body = p.parseArrowBody()
+	if lit.Body == nil {
+		return nil
+	}
+
+	return lit
+}
+
+// parseParenthesizedArrowFunction parses `(a, b) => body`. The current
+// token is the opening parenthesis.
+func (p *Parser) parseParenthesizedArrowFunction() ast.Expression {
+	lit := &ast.FunctionLiteral{}
+
+	if !p.parseFunctionParameters(lit) {
+		return nil
+	}
+
+	if !p.expectPeek(token.ARROW) {
+		return nil
+	}
+	lit.Token = p.curToken
+
+	lit.Body = p.parseArrowBody()
+	if lit.Body == nil {
+		return nil
+	}
+
+	return lit
+}
+
+// parseArrowBody parses the body following '=>': either a block or a single
+// expression, which becomes the block's only statement.
+func (p *Parser) parseArrowBody() *ast.BlockStatement {
+	if p.peekTokenIs(token.LBRACE) {
+		p.nextToken()
+		return p.parseBlockStatement()
+	}
+
+	p.nextToken()
+	stmt := &ast.ExpressionStatement{Token: p.curToken}
+	stmt.Expression = p.parseExpression(LOWEST)
+	if stmt.Expression == nil {
+		return nil
+	}
+
+	return &ast.BlockStatement{Token: stmt.Token, Statements: []ast.Statement{stmt}}
+}
+
+// arrowFunctionAhead reports whether the parenthesis at curToken opens the
+// parameter list of an arrow function, by scanning a copy of the lexer for
+// the matching ')' and checking that '=>' follows it.
+func (p *Parser) arrowFunctionAhead() bool {
+	l := *p.l
+	tok := p.peekToken
+	depth := 1
+
+	for {
+		switch tok.Type {
+		case token.LPAREN:
+			depth++
+		case token.RPAREN:
+			depth--
+			if depth == 0 {
+				return l.NextToken().Type == token.ARROW
+			}
+		case token.EOF:
+			return false
+		}
+		tok = l.NextToken()
+	}
+}
+
+func (p *Parser) parseNullLiteral() ast.Expression {
+	return &ast.NullLiteral{Token: p.curToken}
+}
+
 func (p *Parser) parseGroupedExpression() ast.Expression {
+	if p.arrowFunctionAhead() {
+		return p.parseParenthesizedArrowFunction()
+	}
+
 	p.nextToken()
 
 	exp := p.parseExpression(LOWEST)
@@ -344,6 +803,9 @@ func (p *Parser) parseBlockStatement() *ast.BlockStatement {
 	block := &ast.BlockStatement{Token: p.curToken}
 	block.Statements = []ast.Statement{}
 
+	p.blockDepth++
+	defer func() { p.blockDepth-- }()
+
 	p.nextToken()
 
 	for !p.curTokenIs(token.RBRACE) && !p.curTokenIs(token.EOF) {
@@ -364,7 +826,9 @@ func (p *Parser) parseFunctionLiteral() ast.Expression {
 		return nil
 	}
 
-	lit.Parameters = p.parseFunctionParameters()
+	if !p.parseFunctionParameters(lit) {
+		return nil
+	}
 
 	if !p.expectPeek(token.LBRACE) {
 		return nil
@@ -375,31 +839,88 @@ func (p *Parser) parseFunctionLiteral() ast.Expression {
 	return lit
 }
 
-func (p *Parser) parseFunctionParameters() []*ast.Identifier {
-	identifiers := []*ast.Identifier{}
+func (p *Parser) parseMacroLiteral() ast.Expression {
+	lit := &ast.MacroLiteral{Token: p.curToken}
 
-	if p.peekTokenIs(token.RPAREN) {
-		p.nextToken()
-		return identifiers
+	if !p.expectPeek(token.LPAREN) {
+		return nil
 	}
 
-	p.nextToken()
+	params := &ast.FunctionLiteral{}
+	if !p.parseFunctionParameters(params) {
+		return nil
+	}
 
-	ident := &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
-	identifiers = append(identifiers, ident)
+	if len(params.Defaults) > 0 || params.Rest != nil {
+		p.errors = append(p.errors, "macro parameters cannot have defaults or a rest parameter")
+		return nil
+	}
+	lit.Parameters = params.Parameters
 
-	for p.peekTokenIs(token.COMMA) {
+	if !p.expectPeek(token.LBRACE) {
+		return nil
+	}
+
+	lit.Body = p.parseBlockStatement()
+
+	return lit
+}
+
+func (p *Parser) parseFunctionParameters(lit *ast.FunctionLiteral) bool {
+	lit.Parameters = []*ast.Identifier{}
+	lit.Defaults = map[string]ast.Expression{}
+
+	if p.peekTokenIs(token.RPAREN) {
 		p.nextToken()
+		return true
+	}
+
+	for {
 		p.nextToken()
+
+		if p.curTokenIs(token.ELLIPSIS) {
+			if !p.expectPeek(token.IDENT) {
+				return false
+			}
+			lit.Rest = &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
+
+			if !p.peekTokenIs(token.RPAREN) {
+				msg := fmt.Sprintf("rest parameter %s must be the last parameter",
+					lit.Rest.Value)
+				p.errors = append(p.errors, msg)
+				return false
+			}
+			break
+		}
+
+		if !p.curTokenIs(token.IDENT) {
+			msg := fmt.Sprintf("expected parameter name, got %s instead",
+				p.curToken.Type)
+			p.errors = append(p.errors, msg)
+			return false
+		}
+
 		ident := &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
-		identifiers = append(identifiers, ident)
-	}
+		lit.Parameters = append(lit.Parameters, ident)
+
+		if p.peekTokenIs(token.ASSIGN) {
+			p.nextToken()
+			p.nextToken()
+			lit.Defaults[ident.Value] = p.parseExpression(LOWEST)
+		} else if len(lit.Defaults) > 0 {
+			msg := fmt.Sprintf("parameter %s without default follows a parameter with default",
+				ident.Value)
+			p.errors = append(p.errors, msg)
+			return false
+		}
 
-	if !p.expectPeek(token.RPAREN) {
-		return nil
+		if !p.peekTokenIs(token.COMMA) {
+			break
+		}
+		p.nextToken()
 	}
 
-	return identifiers
+	return p.expectPeek(token.RPAREN)
 }
 
 func (p *Parser) parseCallExpression(function ast.Expression) ast.Expression {
@@ -417,12 +938,12 @@ func (p *Parser) parseExpressionList(end token.TokenType) []ast.Expression {
 	}
 
 	p.nextToken()
-	list = append(list, p.parseExpression(LOWEST))
+	list = append(list, p.parseListElement())
 
 	for p.peekTokenIs(token.COMMA) {
 		p.nextToken()
 		p.nextToken()
-		list = append(list, p.parseExpression(LOWEST))
+		list = append(list, p.parseListElement())
 	}
 
 	if !p.expectPeek(end) {
@@ -432,6 +953,20 @@ func (p *Parser) parseExpressionList(end token.TokenType) []ast.Expression {
 	return list
 }
 
+// parseListElement parses one element of an argument list or array literal,
+// which may be spread with a leading '...'.
+func (p *Parser) parseListElement() ast.Expression {
+	if !p.curTokenIs(token.ELLIPSIS) {
+		return p.parseExpression(LOWEST)
+	}
+
+	spread := &ast.SpreadExpression{Token: p.curToken}
+	p.nextToken()
+	spread.Value = p.parseExpression(LOWEST)
+
+	return spread
+}
+
 func (p *Parser) parseArrayLiteral() ast.Expression {
 	array := &ast.ArrayLiteral{Token: p.curToken}
 
@@ -442,6 +977,7 @@ func (p *Parser) parseArrayLiteral() ast.Expression {
 
 func (p *Parser) parseIndexExpression(left ast.Expression) ast.Expression {
 	exp := &ast.IndexExpression{Token: p.curToken, Left: left}
+	exp.Optional = p.curTokenIs(token.OPT_LBRACKET)
 
 	p.nextToken()
 	exp.Index = p.parseExpression(LOWEST)
@@ -453,9 +989,21 @@ func (p *Parser) parseIndexExpression(left ast.Expression) ast.Expression {
 	return exp
 }
 
+func (p *Parser) parseMemberExpression(left ast.Expression) ast.Expression {
+	exp := &ast.IndexExpression{Token: p.curToken, Left: left}
+
+	if !p.expectPeek(token.IDENT) {
+		return nil
+	}
+
+	exp.Index = &ast.StringLiteral{Token: p.curToken, Value: p.curToken.Literal}
+
+	return exp
+}
+
 func (p *Parser) parseHashLiteral() ast.Expression {
 	hash := &ast.HashLiteral{Token: p.curToken}
-	hash.Pairs = make(map[ast.Expression]ast.Expression)
+	hash.Pairs = []ast.HashPair{}
 
 	for !p.peekTokenIs(token.RBRACE) {
 		p.nextToken()
@@ -468,7 +1016,7 @@ func (p *Parser) parseHashLiteral() ast.Expression {
 		p.nextToken()
 		value := p.parseExpression(LOWEST)
 
-		hash.Pairs[key] = value
+		hash.Pairs = append(hash.Pairs, ast.HashPair{Key: key, Value: value})
 
 		if !p.peekTokenIs(token.RBRACE) && !p.expectPeek(token.COMMA) {
 			return nil
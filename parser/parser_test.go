@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"monkey/ast"
 	"monkey/lexer"
+	"strings"
 	"testing"
 )
 
@@ -41,6 +42,172 @@ func TestLetStatements(t *testing.T) {
 	}
 }
 
+func TestConstAndAssignParsing(t *testing.T) {
+	input := `const x = 5; x = y = 10;`
+
+	l := lexer.New(input)
+	p := New(l)
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	if len(program.Statements) != 2 {
+		t.Fatalf("program.Statements does not contain 2 statements. got=%d",
+			len(program.Statements))
+	}
+
+	letStmt, ok := program.Statements[0].(*ast.LetStatement)
+	if !ok {
+		t.Fatalf("s not *ast.LetStatement. got=%T", program.Statements[0])
+	}
+	if !letStmt.IsConst() {
+		t.Errorf("letStmt.IsConst() is false for %q", letStmt.String())
+	}
+	if letStmt.String() != "const x = 5;" {
+		t.Errorf("letStmt.String() wrong. got=%q", letStmt.String())
+	}
+
+	stmt := program.Statements[1].(*ast.ExpressionStatement)
+	assign, ok := stmt.Expression.(*ast.AssignExpression)
+	if !ok {
+		t.Fatalf("stmt.Expression is not ast.AssignExpression. got=%T", stmt.Expression)
+	}
+	if assign.String() != "(x = (y = 10))" {
+		t.Errorf("assign.String() wrong. got=%q", assign.String())
+	}
+
+	l = lexer.New("1 = 2;")
+	p = New(l)
+	p.ParseProgram()
+
+	errors := p.Errors()
+	if len(errors) == 0 || errors[0] != "cannot assign to 1" {
+		t.Errorf("wrong parser errors. got=%q", errors)
+	}
+}
+
+func TestImportAndExportParsing(t *testing.T) {
+	input := `import "lib/strings.mk" as s; export let x = s.upper; export const y = 1;`
+
+	l := lexer.New(input)
+	p := New(l)
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	if len(program.Statements) != 3 {
+		t.Fatalf("program.Statements does not contain 3 statements. got=%d",
+			len(program.Statements))
+	}
+
+	imp, ok := program.Statements[0].(*ast.ImportStatement)
+	if !ok {
+		t.Fatalf("s not *ast.ImportStatement. got=%T", program.Statements[0])
+	}
+	if imp.Path.Value != "lib/strings.mk" || imp.Alias.Value != "s" {
+		t.Errorf("wrong import. got path=%q alias=%q", imp.Path.Value, imp.Alias.Value)
+	}
+
+	expected := []string{
+		`import "lib/strings.mk" as s;`,
+		`export let x = (s.upper);`,
+		`export const y = 1;`,
+	}
+
+	for i, want := range expected {
+		if got := program.Statements[i].String(); got != want {
+			t.Errorf("statement %d wrong. want=%q, got=%q", i, want, got)
+		}
+	}
+
+	let := program.Statements[1].(*ast.LetStatement)
+	member, ok := let.Value.(*ast.IndexExpression)
+	if !ok || !member.IsMember() {
+		t.Fatalf("let.Value is not a member expression. got=%T", let.Value)
+	}
+	name, ok := member.Index.(*ast.StringLiteral)
+	if !ok || name.Value != "upper" {
+		t.Errorf("member.Index is not \"upper\". got=%T (%+v)", member.Index, member.Index)
+	}
+}
+
+func TestImportAndExportErrors(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`fn() { import "a.mk" as a; }`, "import is only allowed at the top level"},
+		{`if (true) { export let x = 1; }`, "export is only allowed at the top level"},
+		{`export x = 1;`, "expected let or const after export, got IDENT instead"},
+		{`import a as b;`, "expected next token to be STRING, got IDENT instead"},
+		{`import "a.mk";`, "expected next token to be AS, got ; instead"},
+		{`a.1`, "expected next token to be IDENT, got INT instead"},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		p.ParseProgram()
+
+		errors := p.Errors()
+		if len(errors) == 0 || errors[0] != tt.expected {
+			t.Errorf("wrong parser errors for %q. want=%q, got=%q",
+				tt.input, tt.expected, errors)
+		}
+	}
+}
+
+func TestThrowAndTryParsing(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`throw x;`, `throw x;`},
+		{`try { a } catch (e) { b }`, `try {a} catch (e) {b}`},
+		{`try { a } catch { b };`, `try {a} catch {b}`},
+		{`try { a } finally { c }`, `try {a} finally {c}`},
+		{`try { a } catch (e) { throw e; } finally { c }`, `try {a} catch (e) {throw e;} finally {c}`},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		if len(program.Statements) != 1 {
+			t.Fatalf("program.Statements does not contain 1 statement for %q. got=%d",
+				tt.input, len(program.Statements))
+		}
+
+		if got := program.String(); got != tt.expected {
+			t.Errorf("wrong program for %q. want=%q, got=%q", tt.input, tt.expected, got)
+		}
+	}
+}
+
+func TestTryErrors(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`try { a }`, "try without catch or finally"},
+		{`try a`, "expected next token to be {, got IDENT instead"},
+		{`try { a } catch (1) { b }`, "expected next token to be IDENT, got INT instead"},
+		{`try { a } catch (e { b }`, "expected next token to be ), got { instead"},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		p.ParseProgram()
+
+		errors := p.Errors()
+		if len(errors) == 0 || errors[0] != tt.expected {
+			t.Errorf("wrong parser errors for %q. want=%q, got=%q",
+				tt.input, tt.expected, errors)
+		}
+	}
+}
+
 func TestReturnStatements(t *testing.T) {
 	tests := []struct {
 		input         string
@@ -341,6 +508,38 @@ func TestOperatorPrecedenceParsing(t *testing.T) {
 			"add(a + b + c * d / f + g)",
 			"add((((a + b) + ((c * d) / f)) + g))",
 		},
+		{
+			"xs |> f(1) |> g",
+			"((xs |> f(1)) |> g)",
+		},
+		{
+			"a + b |> f() == c",
+			"((a + b) |> (f() == c))",
+		},
+		{
+			"xs |> map(x => x * 2)",
+			"(xs |> map((x) => (x * 2)))",
+		},
+		{
+			"(a, b) => a + b",
+			"(a, b) => (a + b)",
+		},
+		{
+			"(a + b) * c",
+			"((a + b) * c)",
+		},
+		{
+			"a ?? b == c",
+			"(a ?? (b == c))",
+		},
+		{
+			"a?[0] ?? b?[1][2]",
+			"((a?[0]) ?? ((b?[1])[2]))",
+		},
+		{
+			"a ?? b ?? null",
+			"((a ?? b) ?? null)",
+		},
 		{
 			"a * [1, 2, 3, 4][b * c] * d",
 			"((a * ([1, 2, 3, 4][(b * c)])) * d)",
@@ -556,6 +755,61 @@ func TestFunctionLiteralParsing(t *testing.T) {
 	testInfixExpression(t, bodyStmt.Expression, "x", "+", "y")
 }
 
+func TestMacroLiteralParsing(t *testing.T) {
+	input := `macro(x, y) { x + y; }`
+
+	l := lexer.New(input)
+	p := New(l)
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	if len(program.Statements) != 1 {
+		t.Fatalf("program.Statements does not contain %d statements. got=%d\n",
+			1, len(program.Statements))
+	}
+
+	stmt, ok := program.Statements[0].(*ast.ExpressionStatement)
+	if !ok {
+		t.Fatalf("statement is not ast.ExpressionStatement. got=%T",
+			program.Statements[0])
+	}
+
+	macro, ok := stmt.Expression.(*ast.MacroLiteral)
+	if !ok {
+		t.Fatalf("stmt.Expression is not ast.MacroLiteral. got=%T",
+			stmt.Expression)
+	}
+
+	if len(macro.Parameters) != 2 {
+		t.Fatalf("macro literal parameters wrong. want 2, got=%d\n",
+			len(macro.Parameters))
+	}
+
+	testLiteralExpression(t, macro.Parameters[0], "x")
+	testLiteralExpression(t, macro.Parameters[1], "y")
+
+	if len(macro.Body.Statements) != 1 {
+		t.Fatalf("macro.Body.Statements has not 1 statements. got=%d\n",
+			len(macro.Body.Statements))
+	}
+
+	bodyStmt, ok := macro.Body.Statements[0].(*ast.ExpressionStatement)
+	if !ok {
+		t.Fatalf("macro body stmt is not ast.ExpressionStatement. got=%T",
+			macro.Body.Statements[0])
+	}
+
+	testInfixExpression(t, bodyStmt.Expression, "x", "+", "y")
+
+	p = New(lexer.New(`macro(x = 1) { x }`))
+	p.ParseProgram()
+	errors := p.Errors()
+	want := "macro parameters cannot have defaults or a rest parameter"
+	if len(errors) == 0 || errors[0] != want {
+		t.Errorf("wrong parser errors. want=%q, got=%q", want, errors)
+	}
+}
+
 func TestFunctionParameterParsing(t *testing.T) {
 	tests := []struct {
 		input          string
@@ -586,6 +840,304 @@ func TestFunctionParameterParsing(t *testing.T) {
 	}
 }
 
+func TestFunctionDefaultAndRestParameterParsing(t *testing.T) {
+	tests := []struct {
+		input            string
+		expectedParams   []string
+		expectedDefaults map[string]string
+		expectedRest     string
+	}{
+		{
+			input:            "fn(x, y = 10) {};",
+			expectedParams:   []string{"x", "y"},
+			expectedDefaults: map[string]string{"y": "10"},
+		},
+		{
+			input:            "fn(x = 1, y = x * 2) {};",
+			expectedParams:   []string{"x", "y"},
+			expectedDefaults: map[string]string{"x": "1", "y": "(x * 2)"},
+		},
+		{
+			input:            "fn(first, ...rest) {};",
+			expectedParams:   []string{"first"},
+			expectedDefaults: map[string]string{},
+			expectedRest:     "rest",
+		},
+		{
+			input:            "fn(...all) {};",
+			expectedParams:   []string{},
+			expectedDefaults: map[string]string{},
+			expectedRest:     "all",
+		},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		stmt := program.Statements[0].(*ast.ExpressionStatement)
+		function := stmt.Expression.(*ast.FunctionLiteral)
+
+		if len(function.Parameters) != len(tt.expectedParams) {
+			t.Fatalf("length parameters wrong. want %d, got=%d\n",
+				len(tt.expectedParams), len(function.Parameters))
+		}
+
+		for i, ident := range tt.expectedParams {
+			testLiteralExpression(t, function.Parameters[i], ident)
+		}
+
+		if len(function.Defaults) != len(tt.expectedDefaults) {
+			t.Fatalf("length defaults wrong. want %d, got=%d\n",
+				len(tt.expectedDefaults), len(function.Defaults))
+		}
+
+		for name, expected := range tt.expectedDefaults {
+			def, ok := function.Defaults[name]
+			if !ok {
+				t.Errorf("no default for parameter %s", name)
+				continue
+			}
+			if def.String() != expected {
+				t.Errorf("default for %s wrong. want=%q, got=%q",
+					name, expected, def.String())
+			}
+		}
+
+		if tt.expectedRest == "" {
+			if function.Rest != nil {
+				t.Errorf("function.Rest is not nil. got=%s", function.Rest)
+			}
+			continue
+		}
+
+		if function.Rest == nil {
+			t.Fatalf("function.Rest is nil, want %s", tt.expectedRest)
+		}
+		testIdentifier(t, function.Rest, tt.expectedRest)
+	}
+}
+
+func TestFunctionParameterErrors(t *testing.T) {
+	tests := []struct {
+		input         string
+		expectedError string
+	}{
+		{
+			"fn(x = 1, y) {};",
+			"parameter y without default follows a parameter with default",
+		},
+		{
+			"fn(...rest, x) {};",
+			"rest parameter rest must be the last parameter",
+		},
+		{
+			"fn(1) {};",
+			"expected parameter name, got INT instead",
+		},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		p.ParseProgram()
+
+		errors := p.Errors()
+		if len(errors) == 0 {
+			t.Errorf("expected parser errors for %q, got none", tt.input)
+			continue
+		}
+
+		if errors[0] != tt.expectedError {
+			t.Errorf("wrong error for %q. want=%q, got=%q",
+				tt.input, tt.expectedError, errors[0])
+		}
+	}
+}
+
+func TestSpreadExpressionParsing(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"add(...args)", "add(...args)"},
+		{"add(1, ...rest(xs), 2)", "add(1, ...rest(xs), 2)"},
+		{"[0, ...xs, ...[1, 2]]", "[0, ...xs, ...[1, 2]]"},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		if program.String() != tt.expected {
+			t.Errorf("expected=%q, got=%q", tt.expected, program.String())
+		}
+	}
+}
+
+func TestFunctionLiteralWithName(t *testing.T) {
+	input := `let myFunction = fn() { };`
+
+	l := lexer.New(input)
+	p := New(l)
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	stmt, ok := program.Statements[0].(*ast.LetStatement)
+	if !ok {
+		t.Fatalf("program.Statements[0] is not ast.LetStatement. got=%T",
+			program.Statements[0])
+	}
+
+	function, ok := stmt.Value.(*ast.FunctionLiteral)
+	if !ok {
+		t.Fatalf("stmt.Value is not ast.FunctionLiteral. got=%T",
+			stmt.Value)
+	}
+
+	if function.Name != "myFunction" {
+		t.Fatalf("function literal name wrong. want 'myFunction', got=%q\n",
+			function.Name)
+	}
+}
+
+func TestArrowFunctionParsing(t *testing.T) {
+	tests := []struct {
+		input          string
+		expectedParams []string
+		expectedRest   string
+		expectedBody   string
+	}{
+		{"x => x * 2", []string{"x"}, "", "(x * 2)"},
+		{"() => 1", []string{}, "", "1"},
+		{"(a, b) => a + b", []string{"a", "b"}, "", "(a + b)"},
+		{"(a, (b)) => a", nil, "", ""},
+		{"(a, ...rest) => rest", []string{"a"}, "rest", "rest"},
+		{"(a, b = 1) => { let c = a + b; c }", []string{"a", "b"}, "", "let c = (a + b);c"},
+		{"x => y => x + y", []string{"x"}, "", "(y) => (x + y)"},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		program := p.ParseProgram()
+
+		if tt.expectedParams == nil {
+			if len(p.Errors()) == 0 {
+				t.Errorf("expected parser errors for %q, got none", tt.input)
+			}
+			continue
+		}
+		checkParserErrors(t, p)
+
+		stmt := program.Statements[0].(*ast.ExpressionStatement)
+		function, ok := stmt.Expression.(*ast.FunctionLiteral)
+		if !ok {
+			t.Fatalf("stmt.Expression is not ast.FunctionLiteral. got=%T",
+				stmt.Expression)
+		}
+
+		if !function.IsArrow() {
+			t.Errorf("function literal for %q is not an arrow function", tt.input)
+		}
+
+		if len(function.Parameters) != len(tt.expectedParams) {
+			t.Fatalf("length parameters wrong. want %d, got=%d\n",
+				len(tt.expectedParams), len(function.Parameters))
+		}
+
+		for i, ident := range tt.expectedParams {
+			testLiteralExpression(t, function.Parameters[i], ident)
+		}
+
+		if tt.expectedRest != "" {
+			testIdentifier(t, function.Rest, tt.expectedRest)
+		}
+
+		if function.Body.String() != tt.expectedBody {
+			t.Errorf("body wrong. want=%q, got=%q", tt.expectedBody, function.Body.String())
+		}
+	}
+}
+
+func TestPipeExpressionParsing(t *testing.T) {
+	input := "xs |> filter(f)"
+
+	l := lexer.New(input)
+	p := New(l)
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	stmt := program.Statements[0].(*ast.ExpressionStatement)
+	pipe, ok := stmt.Expression.(*ast.PipeExpression)
+	if !ok {
+		t.Fatalf("stmt.Expression is not ast.PipeExpression. got=%T",
+			stmt.Expression)
+	}
+
+	testIdentifier(t, pipe.Left, "xs")
+
+	call := pipe.Call()
+	if call.String() != "filter(xs, f)" {
+		t.Errorf("pipe.Call() wrong. got=%q", call.String())
+	}
+
+	pipe.Right = &ast.Identifier{Value: "g"}
+	if pipe.Call().String() != "g(xs)" {
+		t.Errorf("pipe.Call() wrong. got=%q", pipe.Call().String())
+	}
+}
+
+func TestNullLiteralExpression(t *testing.T) {
+	input := "null;"
+
+	l := lexer.New(input)
+	p := New(l)
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	stmt := program.Statements[0].(*ast.ExpressionStatement)
+	literal, ok := stmt.Expression.(*ast.NullLiteral)
+	if !ok {
+		t.Fatalf("exp not *ast.NullLiteral. got=%T", stmt.Expression)
+	}
+
+	if literal.TokenLiteral() != "null" {
+		t.Errorf("literal.TokenLiteral not %q. got=%q", "null", literal.TokenLiteral())
+	}
+}
+
+func TestParsingOptionalIndexExpressions(t *testing.T) {
+	input := "myArray?[1 + 1]"
+
+	l := lexer.New(input)
+	p := New(l)
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	stmt, ok := program.Statements[0].(*ast.ExpressionStatement)
+	indexExp, ok := stmt.Expression.(*ast.IndexExpression)
+	if !ok {
+		t.Fatalf("exp not *ast.IndexExpression. got=%T", stmt.Expression)
+	}
+
+	if !indexExp.Optional {
+		t.Errorf("indexExp.Optional is false")
+	}
+
+	if !testIdentifier(t, indexExp.Left, "myArray") {
+		return
+	}
+
+	if !testInfixExpression(t, indexExp.Index, 1, "+", 1) {
+		return
+	}
+}
+
 func TestCallExpressionParsing(t *testing.T) {
 	input := "add(1, 2 * 3, 4 + 5);"
 
@@ -697,6 +1249,144 @@ func TestStringLiteralExpression(t *testing.T) {
 	}
 }
 
+func TestStringEscapeParsing(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`"tab\there"`, "tab\there"},
+		{`"line\nbreak\r"`, "line\nbreak\r"},
+		{`"say \"hi\""`, `say "hi"`},
+		{`"back\\slash"`, `back\slash`},
+		{`"\${name}"`, "${name}"},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		stmt := program.Statements[0].(*ast.ExpressionStatement)
+		literal, ok := stmt.Expression.(*ast.StringLiteral)
+		if !ok {
+			t.Fatalf("exp not *ast.StringLiteral. got=%T", stmt.Expression)
+		}
+
+		if literal.Value != tt.expected {
+			t.Errorf("literal.Value not %q. got=%q", tt.expected, literal.Value)
+		}
+	}
+
+	l := lexer.New(`"a\qb"`)
+	p := New(l)
+	p.ParseProgram()
+
+	errors := p.Errors()
+	if len(errors) != 1 || errors[0] != `unknown escape sequence \q in string` {
+		t.Errorf("wrong errors for unknown escape. got=%q", errors)
+	}
+}
+
+func TestInterpolatedStringParsing(t *testing.T) {
+	input := `"Hello ${name}, you have ${len(items) + 1} items"`
+
+	l := lexer.New(input)
+	p := New(l)
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	stmt := program.Statements[0].(*ast.ExpressionStatement)
+	str, ok := stmt.Expression.(*ast.InterpolatedString)
+	if !ok {
+		t.Fatalf("exp not *ast.InterpolatedString. got=%T", stmt.Expression)
+	}
+
+	expected := []string{"Hello ", "name", ", you have ", "(len(items) + 1)", " items"}
+
+	if len(str.Parts) != len(expected) {
+		t.Fatalf("wrong number of parts. want=%d, got=%d",
+			len(expected), len(str.Parts))
+	}
+
+	for i, want := range expected {
+		if str.Parts[i].String() != want {
+			t.Errorf("part %d wrong. want=%q, got=%q", i, want, str.Parts[i].String())
+		}
+	}
+
+	if _, ok := str.Parts[0].(*ast.StringLiteral); !ok {
+		t.Errorf("part 0 not *ast.StringLiteral. got=%T", str.Parts[0])
+	}
+	testIdentifier(t, str.Parts[1], "name")
+}
+
+func TestInterpolatedStringNesting(t *testing.T) {
+	tests := []struct {
+		input         string
+		expectedParts []string
+	}{
+		{`"${a}${b}"`, []string{"a", "b"}},
+		{`"${ {"k": "}"}["k"] }!"`, []string{`({k:}}[k])`, "!"}},
+		{`"outer ${"inner ${x}"}"`, []string{"outer ", `inner ${x}`}},
+		{`"\${a} ${b}\n"`, []string{`\${a} `, "b", `\n`}},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		stmt := program.Statements[0].(*ast.ExpressionStatement)
+		str, ok := stmt.Expression.(*ast.InterpolatedString)
+		if !ok {
+			t.Fatalf("exp not *ast.InterpolatedString. got=%T", stmt.Expression)
+		}
+
+		if len(str.Parts) != len(tt.expectedParts) {
+			t.Fatalf("wrong number of parts for %s. want=%d, got=%d",
+				tt.input, len(tt.expectedParts), len(str.Parts))
+		}
+
+		for i, want := range tt.expectedParts {
+			if str.Parts[i].String() != want {
+				t.Errorf("part %d wrong. want=%q, got=%q", i, want, str.Parts[i].String())
+			}
+		}
+	}
+}
+
+func TestInterpolatedStringErrors(t *testing.T) {
+	tests := []struct {
+		input         string
+		expectedError string
+	}{
+		{`"a ${} b"`, "empty placeholder in string"},
+		{`"a ${1 +} b"`, "in placeholder ${1 +}: no prefix parse function for EOF found"},
+		{`"a ${1 2} b"`, "in placeholder ${1 2}: expected next token to be EOF, got INT instead"},
+		{`puts("x ${`, `unterminated string "x ${`},
+		{`"abc`, `unterminated string "abc`},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		p.ParseProgram()
+
+		errors := p.Errors()
+		if len(errors) == 0 {
+			t.Errorf("expected parser errors for %s, got none", tt.input)
+			continue
+		}
+
+		if errors[0] != tt.expectedError {
+			t.Errorf("wrong error for %s. want=%q, got=%q",
+				tt.input, tt.expectedError, errors[0])
+		}
+	}
+}
+
 func TestParsingEmptyArrayLiterals(t *testing.T) {
 	input := "[]"
 
@@ -805,7 +1495,8 @@ func TestParsingHashLiteralsStringKeys(t *testing.T) {
 		t.Errorf("hash.Pairs has wrong length. got=%d", len(hash.Pairs))
 	}
 
-	for key, value := range hash.Pairs {
+	for _, pair := range hash.Pairs {
+		key, value := pair.Key, pair.Value
 		literal, ok := key.(*ast.StringLiteral)
 		if !ok {
 			t.Errorf("key is not ast.StringLiteral. got=%T", key)
@@ -840,7 +1531,8 @@ func TestParsingHashLiteralsBooleanKeys(t *testing.T) {
 		t.Errorf("hash.Pairs has wrong length. got=%d", len(hash.Pairs))
 	}
 
-	for key, value := range hash.Pairs {
+	for _, pair := range hash.Pairs {
+		key, value := pair.Key, pair.Value
 		boolean, ok := key.(*ast.Boolean)
 		if !ok {
 			t.Errorf("key is not ast.BooleanLiteral. got=%T", key)
@@ -876,7 +1568,8 @@ func TestParsingHashLiteralsIntegerKeys(t *testing.T) {
 		t.Errorf("hash.Pairs has wrong length. got=%d", len(hash.Pairs))
 	}
 
-	for key, value := range hash.Pairs {
+	for _, pair := range hash.Pairs {
+		key, value := pair.Key, pair.Value
 		integer, ok := key.(*ast.IntegerLiteral)
 		if !ok {
 			t.Errorf("key is not ast.IntegerLiteral. got=%T", key)
@@ -889,6 +1582,34 @@ func TestParsingHashLiteralsIntegerKeys(t *testing.T) {
 	}
 }
 
+func TestParsingHashLiteralsKeepSourceOrder(t *testing.T) {
+	input := `{"z": 1, "a": 2, 3: 3, "m": 4}`
+
+	l := lexer.New(input)
+	p := New(l)
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	stmt := program.Statements[0].(*ast.ExpressionStatement)
+	hash, ok := stmt.Expression.(*ast.HashLiteral)
+	if !ok {
+		t.Fatalf("exp is not ast.HashLiteral. got=%T", stmt.Expression)
+	}
+
+	keys := []string{}
+	for _, pair := range hash.Pairs {
+		keys = append(keys, pair.Key.String())
+	}
+
+	if strings.Join(keys, " ") != "z a 3 m" {
+		t.Errorf("hash keys not in source order. got=%q", keys)
+	}
+
+	if hash.String() != "{z:1, a:2, 3:3, m:4}" {
+		t.Errorf("hash.String() wrong. got=%q", hash.String())
+	}
+}
+
 func TestParsingHashLiteralsWithExpressions(t *testing.T) {
 	input := `{"one": 0 + 1, "two": 10 - 8, "three": 15 / 5}`
 
@@ -919,7 +1640,8 @@ func TestParsingHashLiteralsWithExpressions(t *testing.T) {
 		},
 	}
 
-	for key, value := range hash.Pairs {
+	for _, pair := range hash.Pairs {
+		key, value := pair.Key, pair.Value
 		literal, ok := key.(*ast.StringLiteral)
 		if !ok {
 			t.Errorf("key is not ast.StringLiteral. got=%T", key)
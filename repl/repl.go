@@ -1,35 +1,51 @@
 package repl
 
 import (
-	"bufio"
 	"fmt"
 	"io"
+	"monkey/ast"
 	"monkey/compiler"
+	"monkey/evaluator"
 	"monkey/lexer"
 	"monkey/object"
 	"monkey/parser"
 	"monkey/vm"
+	"strings"
 )
 
 const PROMPT = ">> "
 
+// Start reads lines from in and runs them, writing their results to out.
+// The programs read from and print to the same in and out.
 func Start(in io.Reader, out io.Writer) {
-	scanner := bufio.NewScanner(in)
+	StartWithRuntime(object.NewRuntime(object.NewIO(in, out)))
+}
+
+// StartWithRuntime reads lines from the input of runtime and runs them,
+// writing their results to its output. The programs run with runtime, so
+// they share its input and output.
+func StartWithRuntime(runtime *object.Runtime) {
+	out := runtime.IO.Out
 
 	constants := []object.Object{}
 
 	globals := make([]object.Object, vm.GlobalSize)
 
 	symbolTable := compiler.NewSymbolTable()
+	for i, v := range object.Builtins {
+		symbolTable.DefineBuiltin(i, v.Name)
+	}
+
+	macroEnv := object.NewEnvironment()
 
 	for {
 		fmt.Fprintf(out, PROMPT)
-		scanned := scanner.Scan()
-		if !scanned {
+		line, err := runtime.IO.In.ReadString('\n')
+		if err != nil && line == "" {
 			return
 		}
 
-		line := scanner.Text()
+		line = strings.TrimRight(line, "\r\n")
 		l := lexer.New(line)
 		p := parser.New(l)
 
@@ -39,8 +55,18 @@ func Start(in io.Reader, out io.Writer) {
 			continue
 		}
 
+		evaluator.DefineMacros(program, macroEnv)
+		expanded, err := evaluator.ExpandMacros(program, macroEnv)
+		if err != nil {
+			fmt.Fprintf(out, "Woops! Macro expansion failed:\n %s\n", err)
+			continue
+		}
+		if len(program.Statements) == 0 {
+			continue
+		}
+
 		comp := compiler.NewWithState(symbolTable, constants)
-		err := comp.Compile(program)
+		err = comp.Compile(expanded.(*ast.Program))
 
 		if err != nil {
 			fmt.Fprintf(out, "Woops! Compilation failed:\n %s\n", err)
@@ -51,6 +77,7 @@ func Start(in io.Reader, out io.Writer) {
 		constants = code.Constants
 
 		machine := vm.NewWithGlobalStore(code, globals)
+		machine.SetRuntime(runtime)
 
 		err = machine.Run()
 
@@ -61,7 +88,7 @@ func Start(in io.Reader, out io.Writer) {
 
 		stackTop := machine.LastPoppedStackElem()
 
-		io.WriteString(out, stackTop.Inspect())
+		io.WriteString(out, stackTop.Repr())
 		io.WriteString(out, "\n")
 
 	}
@@ -11,6 +11,8 @@ const (
 	INT    = "INT"    // 1343456
 	STRING = "STRING" // "foobar"
 
+	INTERP_STRING = "INTERP_STRING" // "Hello ${name}"
+
 	// Operators
 	ASSIGN   = "="
 	PLUS     = "+"
@@ -25,10 +27,18 @@ const (
 	EQ     = "=="
 	NOT_EQ = "!="
 
+	PIPE  = "|>"
+	ARROW = "=>"
+
+	NULLISH      = "??"
+	OPT_LBRACKET = "?["
+
 	// Delimiters
 	COMMA     = ","
 	SEMICOLON = ";"
 	COLON     = ":"
+	ELLIPSIS  = "..."
+	DOT       = "."
 
 	LPAREN   = "("
 	RPAREN   = ")"
@@ -40,26 +50,47 @@ const (
 	// Keywords
 	FUNCTION = "FUNCTION"
 	LET      = "LET"
+	CONST    = "CONST"
 	TRUE     = "TRUE"
 	FALSE    = "FALSE"
 	IF       = "IF"
 	ELSE     = "ELSE"
 	RETURN   = "RETURN"
+	NULL     = "NULL"
+	IMPORT   = "IMPORT"
+	EXPORT   = "EXPORT"
+	AS       = "AS"
+	THROW    = "THROW"
+	TRY      = "TRY"
+	CATCH    = "CATCH"
+	FINALLY  = "FINALLY"
+	MACRO    = "MACRO"
 )
 
 type Token struct {
 	Type    TokenType
 	Literal string
+	Line    int // line of the first character, starting at 1
 }
 
 var keywords = map[string]TokenType{
-	"fn":     FUNCTION,
-	"let":    LET,
-	"true":   TRUE,
-	"false":  FALSE,
-	"if":     IF,
-	"else":   ELSE,
-	"return": RETURN,
+	"fn":      FUNCTION,
+	"let":     LET,
+	"const":   CONST,
+	"true":    TRUE,
+	"false":   FALSE,
+	"if":      IF,
+	"else":    ELSE,
+	"return":  RETURN,
+	"null":    NULL,
+	"import":  IMPORT,
+	"export":  EXPORT,
+	"as":      AS,
+	"throw":   THROW,
+	"try":     TRY,
+	"catch":   CATCH,
+	"finally": FINALLY,
+	"macro":   MACRO,
 }
 
 func LookupIdent(ident string) TokenType {
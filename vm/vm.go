@@ -1,6 +1,7 @@
 package vm
 
 import (
+	"bytes"
 	"fmt"
 	"monkey/code"
 	"monkey/compiler"
@@ -8,24 +9,60 @@ import (
 )
 
 type VM struct {
-	constants    []object.Object
-	instructions code.Instructions
-	stack        []object.Object
-	sp           int
-	globals      []object.Object
+	constants []object.Object
+	stack     []object.Object
+	sp        int
+	globals   []object.Object
+
+	frames      []*Frame
+	framesIndex int
+
+	handlers []handler
+
+	context *object.Context // of the builtins the program calls
+}
+
+// handler is installed by OpSetupHandler to catch errors raised until the
+// matching OpPopHandler.
+type handler struct {
+	catch       int // address of the catch code in the installing frame
+	framesIndex int
+	sp          int
+}
+
+// thrownError is the error raised by OpThrow.
+type thrownError struct {
+	value *object.Hash
 }
 
+func (e *thrownError) Error() string { return object.ErrorMessage(e.value) }
+
 const StackSize = 2048
 const GlobalSize = 65536
+const MaxFrames = 1024
 
 func New(bytecode *compiler.Bytecode) *VM {
-	return &VM{
-		instructions: bytecode.Instructions,
-		constants:    bytecode.Constants,
-		stack:        make([]object.Object, StackSize),
-		sp:           0,
-		globals:      make([]object.Object, GlobalSize),
+	mainFn := &object.CompiledFunction{
+		Instructions: bytecode.Instructions,
+		Lines:        bytecode.Lines,
 	}
+	mainClosure := &object.Closure{Fn: mainFn}
+	mainFrame := NewFrame(mainClosure, 0)
+
+	frames := make([]*Frame, MaxFrames)
+	frames[0] = mainFrame
+
+	vm := &VM{
+		constants:   bytecode.Constants,
+		stack:       make([]object.Object, StackSize),
+		sp:          0,
+		globals:     make([]object.Object, GlobalSize),
+		frames:      frames,
+		framesIndex: 1,
+	}
+	vm.context = &object.Context{Apply: vm.apply, Runtime: object.DefaultRuntime}
+
+	return vm
 }
 
 func NewWithGlobalStore(bytecode *compiler.Bytecode, s []object.Object) *VM {
@@ -35,9 +72,34 @@ func NewWithGlobalStore(bytecode *compiler.Bytecode, s []object.Object) *VM {
 	return vm
 }
 
-var True = &object.Boolean{Value: true}
-var False = &object.Boolean{Value: false}
-var Null = &object.Null{}
+// SetRuntime sets the runtime of the program's builtins.
+func (vm *VM) SetRuntime(runtime *object.Runtime) {
+	vm.context.Runtime = runtime
+}
+
+var True = object.TRUE
+var False = object.FALSE
+var Null = object.NULL
+
+func (vm *VM) currentFrame() *Frame {
+	return vm.frames[vm.framesIndex-1]
+}
+
+func (vm *VM) pushFrame(f *Frame) error {
+	if vm.framesIndex >= MaxFrames {
+		return fmt.Errorf("stack overflow")
+	}
+
+	vm.frames[vm.framesIndex] = f
+	vm.framesIndex++
+
+	return nil
+}
+
+func (vm *VM) popFrame() *Frame {
+	vm.framesIndex--
+	return vm.frames[vm.framesIndex]
+}
 
 func (vm *VM) LastPoppedStackElem() object.Object {
 	return vm.stack[vm.sp]
@@ -51,16 +113,76 @@ func (vm *VM) StackTop() object.Object {
 	return vm.stack[vm.sp-1]
 }
 
+// Run executes the bytecode. An error raised while a handler is installed
+// is caught by it and execution goes on; otherwise Run returns the error.
 func (vm *VM) Run() error {
+	return vm.runFrames(0)
+}
+
+// runFrames executes instructions until the frames above the first depth
+// frames have returned. Only handlers installed by those frames catch
+// errors.
+func (vm *VM) runFrames(depth int) error {
+	for {
+		err := vm.run(depth)
+		if err == nil || !vm.catch(err, depth) {
+			return err
+		}
+	}
+}
+
+// catch unwinds to the innermost handler and pushes the error hash for
+// err, reporting false if there is no handler installed above the first
+// depth frames.
+func (vm *VM) catch(err error, depth int) bool {
+	if len(vm.handlers) == 0 || vm.handlers[len(vm.handlers)-1].framesIndex <= depth {
+		return false
+	}
+
+	value := vm.errorValue(err)
+
+	h := vm.handlers[len(vm.handlers)-1]
+	vm.handlers = vm.handlers[:len(vm.handlers)-1]
 
-	for ip := 0; ip < len(vm.instructions); ip++ {
-		op := code.Opcode(vm.instructions[ip])
+	vm.framesIndex = h.framesIndex
+	vm.sp = h.sp
+	vm.push(value)
+	vm.currentFrame().ip = h.catch - 1
+
+	return true
+}
+
+// errorValue returns the error hash a catch clause binds for err.
+func (vm *VM) errorValue(err error) *object.Hash {
+	if thrown, ok := err.(*thrownError); ok {
+		return thrown.value
+	}
+	return object.NewErrorHash(err.Error(), object.RUNTIME_ERROR_KIND, vm.currentLine())
+}
+
+// currentLine returns the source line of the instruction being executed.
+func (vm *VM) currentLine() int {
+	frame := vm.currentFrame()
+	return frame.cl.Fn.Lines.LineAt(frame.ip)
+}
+
+func (vm *VM) run(depth int) error {
+	var ip int
+	var ins code.Instructions
+	var op code.Opcode
+
+	for vm.framesIndex > depth && vm.currentFrame().ip < len(vm.currentFrame().Instructions())-1 {
+		vm.currentFrame().ip++
+
+		ip = vm.currentFrame().ip
+		ins = vm.currentFrame().Instructions()
+		op = code.Opcode(ins[ip])
 
 		switch op {
 
 		case code.OpConstant:
-			constIndex := code.ReadUint16(vm.instructions[ip+1:])
-			ip += 2
+			constIndex := code.ReadUint16(ins[ip+1:])
+			vm.currentFrame().ip += 2
 
 			err := vm.push(vm.constants[constIndex])
 
@@ -112,26 +234,26 @@ func (vm *VM) Run() error {
 			vm.pop()
 
 		case code.OpJump:
-			pos := int(code.ReadUint16(vm.instructions[ip+1:]))
-			ip = pos - 1
+			pos := int(code.ReadUint16(ins[ip+1:]))
+			vm.currentFrame().ip = pos - 1
 
 		case code.OpJumpNotNotTruthy:
-			pos := int(code.ReadUint16(vm.instructions[ip+1:]))
-			ip += 2
+			pos := int(code.ReadUint16(ins[ip+1:]))
+			vm.currentFrame().ip += 2
 
 			condition := vm.pop()
 
 			if !isTruthy(condition) {
-				ip = pos - 1
+				vm.currentFrame().ip = pos - 1
 			}
 		case code.OpSetGlobal:
-			globalIndex := code.ReadUint16(vm.instructions[ip+1:])
-			ip += 2
+			globalIndex := code.ReadUint16(ins[ip+1:])
+			vm.currentFrame().ip += 2
 			vm.globals[globalIndex] = vm.pop()
 
 		case code.OpGetGlobal:
-			globalIndex := code.ReadUint16(vm.instructions[ip+1:])
-			ip += 2
+			globalIndex := code.ReadUint16(ins[ip+1:])
+			vm.currentFrame().ip += 2
 
 			err := vm.push(vm.globals[globalIndex])
 
@@ -139,12 +261,445 @@ func (vm *VM) Run() error {
 				return err
 			}
 
+		case code.OpArray:
+			numElements := int(code.ReadUint16(ins[ip+1:]))
+			vm.currentFrame().ip += 2
+
+			array := vm.buildArray(vm.sp-numElements, vm.sp)
+			vm.sp = vm.sp - numElements
+
+			err := vm.push(array)
+			if err != nil {
+				return err
+			}
+
+		case code.OpConcat:
+			numArrays := int(code.ReadUint16(ins[ip+1:]))
+			vm.currentFrame().ip += 2
+
+			array, err := vm.concatArrays(vm.sp-numArrays, vm.sp)
+			if err != nil {
+				return err
+			}
+			vm.sp = vm.sp - numArrays
+
+			err = vm.push(array)
+			if err != nil {
+				return err
+			}
+
+		case code.OpInterpolate:
+			numParts := int(code.ReadUint16(ins[ip+1:]))
+			vm.currentFrame().ip += 2
+
+			str := vm.buildString(vm.sp-numParts, vm.sp)
+			vm.sp = vm.sp - numParts
+
+			err := vm.push(str)
+			if err != nil {
+				return err
+			}
+
+		case code.OpHash:
+			numElements := int(code.ReadUint16(ins[ip+1:]))
+			vm.currentFrame().ip += 2
+
+			hash, err := vm.buildHash(vm.sp-numElements, vm.sp)
+			if err != nil {
+				return err
+			}
+			vm.sp = vm.sp - numElements
+
+			err = vm.push(hash)
+			if err != nil {
+				return err
+			}
+
+		case code.OpIndex:
+			index := vm.pop()
+			left := vm.pop()
+
+			err := vm.executeIndexExpression(left, index)
+			if err != nil {
+				return err
+			}
+
+		case code.OpJumpNull:
+			pos := int(code.ReadUint16(ins[ip+1:]))
+			vm.currentFrame().ip += 2
+
+			if vm.StackTop() == Null {
+				vm.currentFrame().ip = pos - 1
+			}
+
+		case code.OpGetBuiltin:
+			builtinIndex := code.ReadUint8(ins[ip+1:])
+			vm.currentFrame().ip += 1
+
+			definition := object.Builtins[builtinIndex]
+
+			err := vm.push(definition.Builtin)
+			if err != nil {
+				return err
+			}
+
+		case code.OpThrow:
+			return &thrownError{value: object.ThrownError(vm.pop(), vm.currentLine())}
+
+		case code.OpSetupHandler:
+			pos := int(code.ReadUint16(ins[ip+1:]))
+			vm.currentFrame().ip += 2
+
+			vm.handlers = append(vm.handlers, handler{
+				catch:       pos,
+				framesIndex: vm.framesIndex,
+				sp:          vm.sp,
+			})
+
+		case code.OpPopHandler:
+			vm.handlers = vm.handlers[:len(vm.handlers)-1]
+
+		case code.OpSetLocal:
+			localIndex := code.ReadUint8(ins[ip+1:])
+			vm.currentFrame().ip += 1
+
+			frame := vm.currentFrame()
+
+			vm.stack[frame.basePointer+int(localIndex)] = vm.pop()
+
+		case code.OpGetLocal:
+			localIndex := code.ReadUint8(ins[ip+1:])
+			vm.currentFrame().ip += 1
+
+			frame := vm.currentFrame()
+
+			err := vm.push(vm.stack[frame.basePointer+int(localIndex)])
+			if err != nil {
+				return err
+			}
+
+		case code.OpGetFree:
+			freeIndex := code.ReadUint8(ins[ip+1:])
+			vm.currentFrame().ip += 1
+
+			currentClosure := vm.currentFrame().cl
+
+			err := vm.push(currentClosure.Free[freeIndex])
+			if err != nil {
+				return err
+			}
+
+		case code.OpCurrentClosure:
+			currentClosure := vm.currentFrame().cl
+
+			err := vm.push(currentClosure)
+			if err != nil {
+				return err
+			}
+
+		case code.OpClosure:
+			constIndex := code.ReadUint16(ins[ip+1:])
+			numFree := code.ReadUint8(ins[ip+3:])
+			vm.currentFrame().ip += 3
+
+			err := vm.pushClosure(int(constIndex), int(numFree))
+			if err != nil {
+				return err
+			}
+
+		case code.OpJumpIfPassed:
+			paramIndex := int(code.ReadUint8(ins[ip+1:]))
+			pos := int(code.ReadUint16(ins[ip+2:]))
+			vm.currentFrame().ip += 3
+
+			if paramIndex < vm.currentFrame().numArgs {
+				vm.currentFrame().ip = pos - 1
+			}
+
+		case code.OpCall:
+			numArgs := code.ReadUint8(ins[ip+1:])
+			vm.currentFrame().ip += 1
+
+			err := vm.executeCall(int(numArgs))
+			if err != nil {
+				return err
+			}
+
+		case code.OpCallSpread:
+			args := vm.pop().(*object.Array)
+
+			if vm.sp+len(args.Elements) >= StackSize {
+				return fmt.Errorf("stack overflow")
+			}
+
+			for _, arg := range args.Elements {
+				vm.stack[vm.sp] = arg
+				vm.sp++
+			}
+
+			err := vm.executeCall(len(args.Elements))
+			if err != nil {
+				return err
+			}
+
+		case code.OpReturnValue:
+			returnValue := vm.pop()
+
+			if vm.framesIndex == 1 {
+				// A return outside any function ends the program, and the
+				// popped value is its result.
+				vm.currentFrame().ip = len(ins) - 1
+				continue
+			}
+
+			frame := vm.popFrame()
+			vm.sp = frame.basePointer - 1
+
+			err := vm.push(returnValue)
+			if err != nil {
+				return err
+			}
+
+		case code.OpReturn:
+			frame := vm.popFrame()
+			vm.sp = frame.basePointer - 1
+
+			err := vm.push(Null)
+			if err != nil {
+				return err
+			}
+
 		}
 
 	}
 	return nil
 }
 
+func (vm *VM) executeCall(numArgs int) error {
+	callee := vm.stack[vm.sp-1-numArgs]
+	switch callee := callee.(type) {
+	case *object.Closure:
+		return vm.callClosure(callee, numArgs)
+	case *object.Builtin:
+		return vm.callBuiltin(callee, numArgs)
+	default:
+		return fmt.Errorf("not a function: %s", callee.Type())
+	}
+}
+
+// callBuiltin calls fn and raises the error it returns, if any, so that
+// it can be caught like any other.
+func (vm *VM) callBuiltin(builtin *object.Builtin, numArgs int) error {
+	args := vm.stack[vm.sp-numArgs : vm.sp]
+
+	result := builtin.Call(vm.context, args...)
+	vm.sp = vm.sp - numArgs - 1
+
+	if errObj, ok := result.(*object.Error); ok {
+		if errObj.Value != nil {
+			return &thrownError{value: errObj.Value}
+		}
+		kind := errObj.Kind
+		if kind == "" {
+			kind = object.RUNTIME_ERROR_KIND
+		}
+		return &thrownError{value: object.NewErrorHash(errObj.Message, kind, vm.currentLine())}
+	}
+
+	if result != nil {
+		return vm.push(result)
+	}
+	return vm.push(Null)
+}
+
+// apply calls fn with args for a higher-order builtin. The call runs on
+// top of the stack of the builtin's caller and returns once fn's frame has
+// returned. An error that fn does not catch itself is returned as an
+// *object.Error carrying its error hash, so the builtin's caller can catch
+// it after the builtin gives up.
+func (vm *VM) apply(fn object.Object, args ...object.Object) object.Object {
+	sp := vm.sp
+	depth := vm.framesIndex
+
+	err := vm.push(fn)
+	for _, arg := range args {
+		if err != nil {
+			break
+		}
+		err = vm.push(arg)
+	}
+	if err == nil {
+		err = vm.executeCall(len(args))
+	}
+	if err == nil {
+		err = vm.runFrames(depth)
+	}
+
+	if err != nil {
+		value := vm.errorValue(err)
+		vm.framesIndex = depth
+		vm.sp = sp
+		return &object.Error{Message: object.ErrorMessage(value), Value: value}
+	}
+
+	return vm.pop()
+}
+
+func (vm *VM) callClosure(cl *object.Closure, numArgs int) error {
+	fn := cl.Fn
+
+	max := fn.NumParameters
+	if fn.Variadic {
+		max = -1
+	}
+
+	if numArgs < fn.NumRequired || (max >= 0 && numArgs > max) {
+		return fmt.Errorf("%s", object.WrongArgumentCount(numArgs, fn.NumRequired, max))
+	}
+
+	var rest *object.Array
+	if fn.Variadic {
+		numExtra := 0
+		if numArgs > fn.NumParameters {
+			numExtra = numArgs - fn.NumParameters
+		}
+
+		rest = vm.buildArray(vm.sp-numExtra, vm.sp).(*object.Array)
+		vm.sp = vm.sp - numExtra
+		numArgs = numArgs - numExtra
+	}
+
+	frame := NewFrame(cl, vm.sp-numArgs)
+	frame.numArgs = numArgs
+
+	if frame.basePointer+fn.NumLocals >= StackSize {
+		return fmt.Errorf("stack overflow")
+	}
+
+	err := vm.pushFrame(frame)
+	if err != nil {
+		return err
+	}
+
+	vm.sp = frame.basePointer + fn.NumLocals
+
+	if rest != nil {
+		vm.stack[frame.basePointer+fn.NumParameters] = rest
+	}
+
+	return nil
+}
+
+func (vm *VM) pushClosure(constIndex int, numFree int) error {
+	constant := vm.constants[constIndex]
+	function, ok := constant.(*object.CompiledFunction)
+	if !ok {
+		return fmt.Errorf("not a function: %+v", constant)
+	}
+
+	free := make([]object.Object, numFree)
+	for i := 0; i < numFree; i++ {
+		free[i] = vm.stack[vm.sp-numFree+i]
+	}
+	vm.sp = vm.sp - numFree
+
+	closure := &object.Closure{Fn: function, Free: free}
+	return vm.push(closure)
+}
+
+func (vm *VM) buildArray(startIndex, endIndex int) object.Object {
+	elements := make([]object.Object, endIndex-startIndex)
+
+	for i := startIndex; i < endIndex; i++ {
+		elements[i-startIndex] = vm.stack[i]
+	}
+
+	return &object.Array{Elements: elements}
+}
+
+func (vm *VM) buildString(startIndex, endIndex int) object.Object {
+	var out bytes.Buffer
+
+	for i := startIndex; i < endIndex; i++ {
+		out.WriteString(object.ToString(vm.stack[i]))
+	}
+
+	return &object.String{Value: out.String()}
+}
+
+func (vm *VM) buildHash(startIndex, endIndex int) (object.Object, error) {
+	hash := object.NewHash()
+
+	for i := startIndex; i < endIndex; i += 2 {
+		key := vm.stack[i]
+		value := vm.stack[i+1]
+
+		hashKey, ok := object.AsHashable(key)
+		if !ok {
+			return nil, fmt.Errorf("unusable as hash key: %s", key.Type())
+		}
+
+		hash.Set(hashKey, value)
+	}
+
+	return hash, nil
+}
+
+func (vm *VM) executeIndexExpression(left, index object.Object) error {
+	switch {
+	case left.Type() == object.ARRAY_OBJ && index.Type() == object.INTEGER_OBJ:
+		return vm.executeArrayIndex(left, index)
+	case left.Type() == object.HASH_OBJ:
+		return vm.executeHashIndex(left, index)
+	default:
+		return fmt.Errorf("index operator not supported: %s", left.Type())
+	}
+}
+
+func (vm *VM) executeArrayIndex(array, index object.Object) error {
+	arrayObject := array.(*object.Array)
+	i := index.(*object.Integer).Value
+	max := int64(len(arrayObject.Elements) - 1)
+
+	if i < 0 || i > max {
+		return vm.push(Null)
+	}
+
+	return vm.push(arrayObject.Elements[i])
+}
+
+func (vm *VM) executeHashIndex(hash, index object.Object) error {
+	hashObject := hash.(*object.Hash)
+
+	key, ok := object.AsHashable(index)
+	if !ok {
+		return fmt.Errorf("unusable as hash key: %s", index.Type())
+	}
+
+	value, ok := hashObject.Get(key)
+	if !ok {
+		return vm.push(Null)
+	}
+
+	return vm.push(value)
+}
+
+func (vm *VM) concatArrays(startIndex, endIndex int) (object.Object, error) {
+	elements := []object.Object{}
+
+	for i := startIndex; i < endIndex; i++ {
+		array, ok := vm.stack[i].(*object.Array)
+		if !ok {
+			return nil, fmt.Errorf("spread operand must be ARRAY, got %s",
+				vm.stack[i].Type())
+		}
+
+		elements = append(elements, array.Elements...)
+	}
+
+	return &object.Array{Elements: elements}, nil
+}
+
 func (vm *VM) executeBinaryOperation(op code.Opcode) error {
 	right := vm.pop()
 	left := vm.pop()
@@ -223,9 +778,9 @@ func (vm *VM) executeComparision(op code.Opcode) error {
 
 	switch op {
 	case code.OpEqual:
-		return vm.push(nativeBooleanObject(right == left))
+		return vm.push(nativeBooleanObject(object.Equal(left, right)))
 	case code.OpNotEqual:
-		return vm.push(nativeBooleanObject(right != left))
+		return vm.push(nativeBooleanObject(!object.Equal(left, right)))
 
 	default:
 		return fmt.Errorf("unknown operator: %d (%s %s)", op, left.Type(), right.Type())
@@ -1,13 +1,21 @@
 package vm
 
 import (
+	"bytes"
 	"fmt"
+	"math/rand"
 	"monkey/ast"
 	"monkey/compiler"
+	"monkey/evaluator"
 	"monkey/lexer"
+	"monkey/module"
 	"monkey/object"
 	"monkey/parser"
+	"os"
+	"path/filepath"
+	"strings"
 	"testing"
+	"time"
 )
 
 func parse(input string) *ast.Program {
@@ -95,6 +103,67 @@ func runVmTests(t *testing.T, tests []vmTestCase) {
 	}
 }
 
+// vmReprTestCase expects the Repr of the result of input, or "ERROR: "
+// followed by the message of the error it fails with.
+type vmReprTestCase struct {
+	input        string
+	expectedRepr string
+}
+
+func runVmReprTests(t *testing.T, tests []vmReprTestCase) {
+	t.Helper()
+
+	for _, tt := range tests {
+		program := parse(tt.input)
+
+		comp := compiler.New()
+		if err := comp.Compile(program); err != nil {
+			t.Fatalf("compile error %s", err)
+		}
+
+		vm := New(comp.Bytecode())
+
+		var got string
+		if err := vm.Run(); err != nil {
+			got = "ERROR: " + err.Error()
+		} else {
+			got = vm.LastPoppedStackElem().Repr()
+		}
+
+		if got != tt.expectedRepr {
+			t.Errorf("wrong result for %s. want=%s, got=%s",
+				tt.input, tt.expectedRepr, got)
+		}
+	}
+}
+
+// runEngines runs input in the VM and in the evaluator and returns the
+// Repr of both results, with errors rendered as "ERROR: " and the message.
+func runEngines(input string) (string, string) {
+	var vmResult string
+	comp := compiler.New()
+	if err := comp.Compile(parse(input)); err != nil {
+		vmResult = "ERROR: " + err.Error()
+	} else {
+		vm := New(comp.Bytecode())
+		if err := vm.Run(); err != nil {
+			vmResult = "ERROR: " + err.Error()
+		} else {
+			vmResult = vm.LastPoppedStackElem().Repr()
+		}
+	}
+
+	var evalResult string
+	evaluated := evaluator.Eval(parse(input), object.NewEnvironment())
+	if errObj, ok := evaluated.(*object.Error); ok {
+		evalResult = "ERROR: " + errObj.Message
+	} else {
+		evalResult = evaluated.Repr()
+	}
+
+	return vmResult, evalResult
+}
+
 func testExpectedObject(t *testing.T, expected interface{}, actual object.Object) {
 
 	t.Helper()
@@ -115,6 +184,50 @@ func testExpectedObject(t *testing.T, expected interface{}, actual object.Object
 		if err != nil {
 			t.Errorf("testStringObject failed: %s", err)
 		}
+	case []int:
+		array, ok := actual.(*object.Array)
+		if !ok {
+			t.Errorf("object not Array: %T (%+v)", actual, actual)
+			return
+		}
+
+		if len(array.Elements) != len(expected) {
+			t.Errorf("wrong num of elements. want=%d, got=%d",
+				len(expected), len(array.Elements))
+			return
+		}
+
+		for i, expectedElem := range expected {
+			err := testIntegerObject(int64(expectedElem), array.Elements[i])
+			if err != nil {
+				t.Errorf("testIntegerObject failed: %s", err)
+			}
+		}
+	case map[object.Hashable]int64:
+		hash, ok := actual.(*object.Hash)
+		if !ok {
+			t.Errorf("object is not Hash. got=%T (%+v)", actual, actual)
+			return
+		}
+
+		if hash.Len() != len(expected) {
+			t.Errorf("hash has wrong number of Pairs. want=%d, got=%d",
+				len(expected), hash.Len())
+			return
+		}
+
+		for expectedKey, expectedValue := range expected {
+			value, ok := hash.Get(expectedKey)
+			if !ok {
+				t.Errorf("no pair for given key in Pairs")
+				continue
+			}
+
+			err := testIntegerObject(expectedValue, value)
+			if err != nil {
+				t.Errorf("testIntegerObject failed: %s", err)
+			}
+		}
 	case *object.Null:
 		if actual != Null {
 			t.Errorf("object is not Null: %T (%+v)", actual, actual)
@@ -216,3 +329,973 @@ func TestStringExpressions(t *testing.T) {
 
 	runVmTests(t, tests)
 }
+
+func TestInterpolatedStrings(t *testing.T) {
+	tests := []vmTestCase{
+		{`"plain ${"text"}"`, "plain text"},
+		{`let name = "Monkey"; "Hello ${name}!"`, "Hello Monkey!"},
+		{`"${1 + 2}${true}${[1, 2]}"`, "3true[1, 2]"},
+		{`"${if (false) { 1 }} value"`, "null value"},
+		{`let n = 2; "outer ${"inner ${n * 2}"}"`, "outer inner 4"},
+		{`let greet = fn(who) { "hi ${who}" }; greet(42)`, "hi 42"},
+		{`"\${n} is ${1 + 1}\t\"two\""`, "${n} is 2\t\"two\""},
+	}
+
+	runVmTests(t, tests)
+}
+
+func TestStringBuiltins(t *testing.T) {
+	tests := []vmReprTestCase{
+		{`split("a,b,,c", ",")`, `["a", "b", "", "c"]`},
+		{`split("abc", "")`, `["a", "b", "c"]`},
+		{`join(["a", 1, true], "-")`, `"a-1-true"`},
+		{`join([], ", ")`, `""`},
+		{`trim("  hi\n")`, `"hi"`},
+		{`upper("Monkey")`, `"MONKEY"`},
+		{`lower("Monkey")`, `"monkey"`},
+		{`contains("monkey", "key")`, "true"},
+		{`if (contains("monkey", "ape")) { 1 } else { 2 }`, "2"},
+		{`starts_with("monkey", "mon")`, "true"},
+		{`ends_with("monkey", "mon")`, "false"},
+		{`replace("a-b-c", "-", "+")`, `"a+b+c"`},
+		{`index_of("monkey", "key")`, "3"},
+		{`index_of("monkey", "ape")`, "-1"},
+		{`repeat("ab", 3)`, `"ababab"`},
+		{`chars("añb")`, `["a", "ñ", "b"]`},
+		{`split("a")`, "ERROR: wrong number of arguments. got=1, want=2"},
+		{`upper(1)`, "ERROR: argument to `upper` must be STRING, got INTEGER"},
+		{`replace("a", "b", 3)`, "ERROR: argument to `replace` must be STRING, got INTEGER"},
+		{`join("a", ",")`, "ERROR: argument to `join` must be ARRAY, got STRING"},
+		{`repeat("a", -1)`, "ERROR: argument to `repeat` must not be negative, got -1"},
+		{`repeat("ab", 9223372036854775807)`, "ERROR: result of `repeat` would exceed 1073741824 bytes"},
+		{`repeat("", 9223372036854775807)`, `""`},
+	}
+
+	runVmReprTests(t, tests)
+}
+
+func TestHigherOrderBuiltins(t *testing.T) {
+	tests := []vmReprTestCase{
+		{`map([1, 2, 3], fn(x) { x * 2 })`, "[2, 4, 6]"},
+		{`map(["a", "b"], upper)`, `["A", "B"]`},
+		{`let n = 10; map([1, 2], fn(x) { x + n })`, "[11, 12]"},
+		{`map([[1, 2], [3]], fn(xs) { map(xs, fn(x) { -x }) })`, "[[-1, -2], [-3]]"},
+		{`filter([1, 2, 3, 4], fn(x) { x > 2 })`, "[3, 4]"},
+		{`filter([1, null, false, 0], fn(x) { x })`, "[1, 0]"},
+		{`reduce([1, 2, 3, 4], 0, fn(acc, x) { acc + x })`, "10"},
+		{`reduce([], "none", fn(acc, x) { x })`, `"none"`},
+		{`sort_by([3, 1, 2], fn(x) { x })`, "[1, 2, 3]"},
+		{`sort_by(["bb", "a", "cc", "d"], len)`, `["a", "d", "bb", "cc"]`},
+		{`any([1, 2, 3], fn(x) { x > 2 })`, "true"},
+		{`any([], fn(x) { true })`, "false"},
+		{`all([1, 2, 3], fn(x) { x > 0 })`, "true"},
+		{`all([1, 2, 3], fn(x) { x > 1 })`, "false"},
+		{`each([1, 2], fn(x) { x })`, "null"},
+		{`map([1, 2], fn(x) { let r = 0; try { throw x } catch (e) { r = e.message }; r })`, `["1", "2"]`},
+		{`let r = ""; try { map([1, 2], fn(x) { throw "boom" }) } catch (e) { r = e.message }; r`, `"boom"`},
+		{`let r = ""; try { map([1], fn(x) { x + "a" }) } catch (e) { r = e.kind }; r`, `"RuntimeError"`},
+		{`map([1, 2], fn(x) { throw "boom" })`, "ERROR: boom"},
+		{`map([1], fn(x, y) { x })`, "ERROR: wrong number of arguments. got=1, want=2"},
+		{`map(1, fn(x) { x })`, "ERROR: argument to `map` must be ARRAY, got INTEGER"},
+		{`filter([1], 1)`, "ERROR: argument to `filter` must be a function, got INTEGER"},
+		{`reduce([1], fn(a, b) { a })`, "ERROR: wrong number of arguments. got=2, want=3"},
+		{`sort_by([1, "a"], fn(x) { x })`, "ERROR: keys of `sort_by` must have the same type, got INTEGER and STRING"},
+		{`sort_by([true], fn(x) { x })`, "ERROR: keys of `sort_by` must be INTEGER or STRING, got BOOLEAN"},
+	}
+
+	runVmReprTests(t, tests)
+}
+
+func TestHashBuiltins(t *testing.T) {
+	tests := []vmReprTestCase{
+		{`keys({"b": 1, "a": 2, 3: 3})`, `["b", "a", 3]`},
+		{`values({"b": 1, "a": 2})`, "[1, 2]"},
+		{`items({"b": 1, [1]: 2})`, `[["b", 1], [[1], 2]]`},
+		{`keys({})`, "[]"},
+		{`has({"a": 1}, "a")`, "true"},
+		{`has({[1, 2]: 1}, [1, 2])`, "true"},
+		{`has({"a": 1}, "b")`, "false"},
+		{`delete({"a": 1, "b": 2, "c": 3}, "b")`, `{"a": 1, "c": 3}`},
+		{`delete({"a": 1}, "b")`, `{"a": 1}`},
+		{`let h = {"a": 1}; delete(h, "a"); h`, `{"a": 1}`},
+		{`merge({"a": 1, "b": 2}, {"c": 3, "a": 4})`, `{"a": 4, "b": 2, "c": 3}`},
+		{`let h = {"a": 1}; merge(h, {"a": 2}); h["a"]`, "1"},
+		{`len({"a": 1, "b": 2})`, "2"},
+		{`keys([1])`, "ERROR: argument to `keys` must be HASH, got ARRAY"},
+		{`has({}, fn(x) { x })`, "ERROR: unusable as hash key: CLOSURE"},
+		{`merge({}, 1)`, "ERROR: argument to `merge` must be HASH, got INTEGER"},
+		{`delete({})`, "ERROR: wrong number of arguments. got=1, want=2"},
+	}
+
+	runVmReprTests(t, tests)
+}
+
+func TestTypeBuiltins(t *testing.T) {
+	tests := []vmReprTestCase{
+		{`type(1)`, `"INTEGER"`},
+		{`type("a")`, `"STRING"`},
+		{`type(null)`, `"NULL"`},
+		{`type([1])`, `"ARRAY"`},
+		{`type({})`, `"HASH"`},
+		{`type(fn() {})`, `"FUNCTION"`},
+		{`type(len)`, `"BUILTIN"`},
+		{`type(1) == "INTEGER"`, "true"},
+		{`type(1) == type(2)`, "true"},
+		{`type("1") == type(1)`, "false"},
+		{`let t = type(true); if (t == "BOOLEAN") { "yes" } else { "no" }`, `"yes"`},
+		{`str(12)`, `"12"`},
+		{`str("a")`, `"a"`},
+		{`str([1, "a"])`, `"[1, a]"`},
+		{`int("42")`, "42"},
+		{`int("-7")`, "-7"},
+		{`int("010")`, "10"},
+		{`int("0x10")`, `ERROR: could not parse "0x10" as integer`},
+		{`int(true)`, "1"},
+		{`int(5)`, "5"},
+		{`bool(0)`, "true"},
+		{`bool(null)`, "false"},
+		{`bool("")`, "true"},
+		{`int("4x")`, `ERROR: could not parse "4x" as integer`},
+		{`let r = ""; try { int("x") } catch (e) { r = e.kind }; r`, `"RuntimeError"`},
+		{`int([])`, "ERROR: argument to `int` not supported, got ARRAY"},
+		{`type()`, "ERROR: wrong number of arguments. got=0, want=1"},
+	}
+
+	runVmReprTests(t, tests)
+}
+
+func TestJSONBuiltins(t *testing.T) {
+	tests := []vmReprTestCase{
+		{`json_encode({"b": [1, true, null], "a": "x\"y<"})`, `"{\"b\":[1,true,null],\"a\":\"x\\\"y<\"}"`},
+		{`json_encode([])`, `"[]"`},
+		{`json_encode({"a": [1]}, 2)`, `"{\n  \"a\": [\n    1\n  ]\n}"`},
+		{`json_encode({1: 2})`, "ERROR: JSON object keys must be STRING, got INTEGER"},
+		{`json_encode([len])`, "ERROR: cannot encode BUILTIN as JSON"},
+		{`json_encode(fn() {})`, "ERROR: cannot encode FUNCTION as JSON"},
+		{`json_decode("{\"z\": 1, \"a\": [true, null, \"s\"], \"z\": 2}")`, `{"z": 2, "a": [true, null, "s"]}`},
+		{`json_decode(" 42 ")`, "42"},
+		{`let v = json_decode("[null]"); if (v[0]) { 1 } else { 2 }`, "2"},
+		{`json_decode(json_encode({"k": ["v"]}))["k"]`, `["v"]`},
+		{`json_decode("1.5")`, "ERROR: invalid JSON: number 1.5 is not an integer"},
+		{`json_decode("[1,")`, "ERROR: invalid JSON: unexpected end of JSON input"},
+		{`json_decode("1 2")`, "ERROR: invalid JSON: unexpected data after value"},
+		{`json_decode("")`, "ERROR: invalid JSON: unexpected end of JSON input"},
+		{`json_decode(1)`, "ERROR: argument to `json_decode` must be STRING, got INTEGER"},
+	}
+
+	runVmReprTests(t, tests)
+}
+
+func TestIOBuiltins(t *testing.T) {
+	tests := []struct {
+		input          string
+		stdin          string
+		expectedOutput string
+		expectedRepr   string
+	}{
+		{`puts("a", 1); print("b", [2]); print("c")`, "", "a\n1\nb[2]c", "null"},
+		{`let name = input("name? "); "hi " + name`, "Bob\n", "name? ", `"hi Bob"`},
+		{`[read_line(), read_line(), read_line()]`, "one\r\ntwo", "", `["one", "two", null]`},
+		{`input()`, "", "", "null"},
+		{`each([1, 2], fn(x) { puts(x * 10) })`, "", "10\n20\n", "null"},
+		{`read_line(1)`, "", "", "ERROR: wrong number of arguments. got=1, want=0"},
+	}
+
+	for _, tt := range tests {
+		comp := compiler.New()
+		if err := comp.Compile(parse(tt.input)); err != nil {
+			t.Fatalf("compile error %s", err)
+		}
+
+		var out bytes.Buffer
+
+		vm := New(comp.Bytecode())
+		vm.SetRuntime(object.NewRuntime(object.NewIO(strings.NewReader(tt.stdin), &out)))
+
+		var got string
+		if err := vm.Run(); err != nil {
+			got = "ERROR: " + err.Error()
+		} else {
+			got = vm.LastPoppedStackElem().Repr()
+		}
+
+		if out.String() != tt.expectedOutput {
+			t.Errorf("wrong output for %s. want=%q, got=%q",
+				tt.input, tt.expectedOutput, out.String())
+		}
+		if got != tt.expectedRepr {
+			t.Errorf("wrong result for %s. want=%s, got=%s",
+				tt.input, tt.expectedRepr, got)
+		}
+	}
+}
+
+func TestMathBuiltins(t *testing.T) {
+	tests := []vmReprTestCase{
+		{`abs(-5)`, "5"},
+		{`abs(3)`, "3"},
+		{`min(3, 1, 2)`, "1"},
+		{`min([4, -2, 9])`, "-2"},
+		{`max(3, 1, 2)`, "3"},
+		{`max([7])`, "7"},
+		{`pow(2, 10)`, "1024"},
+		{`pow(-3, 3)`, "-27"},
+		{`pow(5, 0)`, "1"},
+		{`pow(-1, 1000000000000000001)`, "-1"},
+		{`pow(0, 1000000000000000000)`, "0"},
+		{`pow(2, 62)`, "4611686018427387904"},
+		{`pow(-2, 63)`, "-9223372036854775808"},
+		{`gcd(12, 18)`, "6"},
+		{`gcd(-4, 6)`, "2"},
+		{`gcd(0, 0)`, "0"},
+		{`clamp(15, 0, 10)`, "10"},
+		{`clamp(-1, 0, 10)`, "0"},
+		{`clamp(5, 0, 10)`, "5"},
+		{`sum([1, 2, 3])`, "6"},
+		{`sum([])`, "0"},
+		{`abs(-9223372036854775807 - 1)`, "ERROR: integer overflow in `abs`"},
+		{`pow(2, 63)`, "ERROR: integer overflow in `pow`"},
+		{`pow(2, -1)`, "ERROR: argument to `pow` must not be negative, got -1"},
+		{`sum([9223372036854775807, 1])`, "ERROR: integer overflow in `sum`"},
+		{`min()`, "ERROR: wrong number of arguments. got=0, want at least 1"},
+		{`max([])`, "ERROR: argument to `max` must not be empty"},
+		{`min(1, "a")`, "ERROR: argument to `min` must be INTEGER, got STRING"},
+		{`min([1], 2)`, "ERROR: argument to `min` must be INTEGER, got ARRAY"},
+		{`clamp(1, 5, 0)`, "ERROR: bounds of `clamp` are out of order: 5 > 0"},
+		{`sum(1)`, "ERROR: argument to `sum` must be ARRAY, got INTEGER"},
+	}
+
+	runVmReprTests(t, tests)
+}
+
+func TestRandomAndClockBuiltins(t *testing.T) {
+	input := `
+let rolls = map([1, 2, 3, 4, 5], fn(i) { rand_int(1, 6) });
+let start = now();
+sleep(1500);
+[rolls, shuffle([1, 2, 3, 4]), choice(["x", "y", "z"]), now() - start, start]
+`
+	newRuntime := func() *object.Runtime {
+		return &object.Runtime{
+			IO:    object.StdIO,
+			Rand:  rand.New(rand.NewSource(42)),
+			Clock: &object.FakeClock{Time: time.UnixMilli(1000)},
+		}
+	}
+
+	comp := compiler.New()
+	if err := comp.Compile(parse(input)); err != nil {
+		t.Fatalf("compile error %s", err)
+	}
+
+	vm := New(comp.Bytecode())
+	vm.SetRuntime(newRuntime())
+	if err := vm.Run(); err != nil {
+		t.Fatalf("vm error: %s", err)
+	}
+
+	// The same seed gives the same numbers in both engines.
+	env := object.NewEnvironment()
+	env.SetRuntime(newRuntime())
+	evaluated := evaluator.Eval(parse(input), env)
+
+	got := vm.LastPoppedStackElem().Repr()
+	if got != evaluated.Repr() {
+		t.Errorf("engines differ. vm=%s, evaluator=%s", got, evaluated.Repr())
+	}
+	if !strings.HasSuffix(got, ", 1500, 1000]") {
+		t.Errorf("wrong clock results. got=%s", got)
+	}
+
+	runVmReprTests(t, []vmReprTestCase{
+		{`rand_int(3, 1)`, "ERROR: bounds of `rand_int` are out of order: 3 > 1"},
+		{`rand_int(-9223372036854775807, 9223372036854775807)`, "ERROR: range of `rand_int` is too large: -9223372036854775807..9223372036854775807"},
+		{`rand_int(5, 5)`, "5"},
+		{`choice([])`, "ERROR: argument to `choice` must not be empty"},
+		{`shuffle(1)`, "ERROR: argument to `shuffle` must be ARRAY, got INTEGER"},
+		{`sort_by(shuffle([3, 1, 2]), fn(x) { x })`, "[1, 2, 3]"},
+		{`sleep(-1)`, "ERROR: argument to `sleep` must not be negative, got -1"},
+		{`now(1)`, "ERROR: wrong number of arguments. got=1, want=0"},
+	})
+}
+
+func TestRegexpBuiltins(t *testing.T) {
+	tests := []vmReprTestCase{
+		{`re_match("(\\w+)@(\\w+)", "mail bob@example now")`, `["bob@example", "bob", "example"]`},
+		{`re_match("(?P<user>\\w+)@(\\w+)", "bob@example")`, `{0: "bob@example", "user": "bob", 2: "example"}`},
+		{`re_match("a(x)?b", "ab")`, `["ab", null]`},
+		{`re_match("\\d", "abc")`, "null"},
+		{`if (re_match("^\\d+$", "123")) { "digits" }`, `"digits"`},
+		{`re_find_all("\\d+", "a1b22c333")`, `["1", "22", "333"]`},
+		{`re_find_all("(\\w)=(\\d)", "a=1, b=2")`, `[["a=1", "a", "1"], ["b=2", "b", "2"]]`},
+		{`re_find_all("x", "abc")`, "[]"},
+		{`re_replace("(\\w+)@(\\w+)", "bob@example", "$2 at $1")`, `"example at bob"`},
+		{`re_replace("(?P<n>\\d)", "a1b2", "<\${n}>")`, `"a<1>b<2>"`},
+		{`re_split("\\s*,\\s*", "a , b,c")`, `["a", "b", "c"]`},
+		{`re_match("(", "x")`, "ERROR: invalid regular expression \"(\": missing closing )"},
+		{`re_split("[", "x")`, "ERROR: invalid regular expression \"[\": missing closing ]"},
+		{`re_match(1, "x")`, "ERROR: argument to `re_match` must be STRING, got INTEGER"},
+		{`re_replace("a", "b", 1)`, "ERROR: argument to `re_replace` must be STRING, got INTEGER"},
+		{`re_find_all("a")`, "ERROR: wrong number of arguments. got=1, want=2"},
+	}
+
+	runVmReprTests(t, tests)
+}
+
+func TestArrayLiterals(t *testing.T) {
+	tests := []vmTestCase{
+		{"[]", []int{}},
+		{"[1, 2, 3]", []int{1, 2, 3}},
+		{"[1 + 2, 3 * 4, 5 + 6]", []int{3, 12, 11}},
+	}
+
+	runVmTests(t, tests)
+}
+
+func TestHashLiterals(t *testing.T) {
+	tests := []vmTestCase{
+		{
+			"{}", map[object.Hashable]int64{},
+		},
+		{
+			"{1: 2, 2: 3}",
+			map[object.Hashable]int64{
+				&object.Integer{Value: 1}: 2,
+				&object.Integer{Value: 2}: 3,
+			},
+		},
+		{
+			"{1 + 1: 2 * 2, 3 + 3: 4 * 4}",
+			map[object.Hashable]int64{
+				&object.Integer{Value: 2}: 4,
+				&object.Integer{Value: 6}: 16,
+			},
+		},
+	}
+
+	runVmTests(t, tests)
+}
+
+func TestStructuralEquality(t *testing.T) {
+	tests := []vmTestCase{
+		{"[1, 2] == [1, 2]", true},
+		{"[1, [2, 3]] == [1, [2, 3]]", true},
+		{"[1, 2] == [2, 1]", false},
+		{"[1, 2] != [1, 2, 3]", true},
+		{`{"a": 1, "b": [1]} == {"b": [1], "a": 1}`, true},
+		{`{"a": 1} == {"a": 2}`, false},
+		{`"ab" == "a" + "b"`, true},
+		{`"a" != "b"`, true},
+		{`[] == {}`, false},
+		{`null == null`, true},
+		{`let k = [1, "x"]; let h = {k: 5}; h[[1, "x"]] == 5`, true},
+		{`{[1, [2]]: "n"}[[1, [2]]] == "n"`, true},
+		{`{[1, 2]: 1, [1, 2]: 2}[[1, 2]] == 2`, true},
+	}
+
+	runVmTests(t, tests)
+
+	comp := compiler.New()
+	if err := comp.Compile(parse(`{[fn() {}]: 1}`)); err != nil {
+		t.Fatalf("compiler error: %s", err)
+	}
+	err := New(comp.Bytecode()).Run()
+	if err == nil || err.Error() != "unusable as hash key: ARRAY" {
+		t.Errorf("wrong error for unhashable array key. got=%v", err)
+	}
+}
+
+func TestHashOrder(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`{"b": 1, "a": 2, 3: 3, true: 4}`, "{b: 1, a: 2, 3: 3, true: 4}"},
+		{`{"b": 1, "a": 2, "b": 3}`, "{b: 3, a: 2}"},
+		{`let k = "z"; {k: 1, "y": {"q": 2, "p": 3}}`, "{z: 1, y: {q: 2, p: 3}}"},
+	}
+
+	for _, tt := range tests {
+		comp := compiler.New()
+		if err := comp.Compile(parse(tt.input)); err != nil {
+			t.Fatalf("compiler error: %s", err)
+		}
+
+		vm := New(comp.Bytecode())
+		if err := vm.Run(); err != nil {
+			t.Fatalf("vm error: %s", err)
+		}
+
+		got := vm.LastPoppedStackElem().Inspect()
+		if got != tt.expected {
+			t.Errorf("wrong hash for %q. want=%q, got=%q", tt.input, tt.expected, got)
+		}
+	}
+}
+
+func TestIndexExpressions(t *testing.T) {
+	tests := []vmTestCase{
+		{"[1, 2, 3][1]", 2},
+		{"[[1, 1, 1]][0][0]", 1},
+		{"[][0]", Null},
+		{"[1, 2, 3][99]", Null},
+		{"[1][-1]", Null},
+		{"{1: 1, 2: 2}[1]", 1},
+		{"{1: 1, 2: 2}[2]", 2},
+		{"{1: 1}[0]", Null},
+		{"{}[0]", Null},
+	}
+
+	runVmTests(t, tests)
+}
+
+func TestNullOperators(t *testing.T) {
+	tests := []vmTestCase{
+		{"null", Null},
+		{"null == null", true},
+		{"null != null", false},
+		{"[1, 2][5] == null", true},
+		{`{"a": 1}["a"] == null`, false},
+		{"!null", true},
+		{"let f = fn() { return null; }; f()", Null},
+		{"null ?? 5", 5},
+		{"1 ?? 5", 1},
+		{"false ?? 5", false},
+		{`{"a": 1}["b"] ?? 2`, 2},
+		{"null ?? null ?? 3", 3},
+		{"null?[0]", Null},
+		{"[1, 2]?[1]", 2},
+		{`let h = {"a": {"b": 7}}; h?["a"]?["b"]`, 7},
+		{`let h = {"a": {"b": 7}}; h?["x"]?["b"]`, Null},
+		{`let h = {"a": {"b": 7}}; h?["x"]?["b"] ?? 0`, 0},
+		{`let get = fn(h, k) { h?[k] ?? "none" }; get(null, "a")`, "none"},
+		{`let h = null; h?["a"]["b"]`, Null},
+		{`let h = {"a": {"b": 7}}; h?["a"]["b"]`, 7},
+		{`let h = null; h?["a"]["b"][0] ?? 1`, 1},
+		{`let h = null; h?["f"](1)`, Null},
+		{`let h = {"f": fn(x) { [x] }}; h?["f"](1)[0]`, 1},
+		{`let h = null; [h?["a"]["b"], 2][1]`, 2},
+		{`let h = {"a": null}; h["a"]?["b"]["c"]`, Null},
+	}
+
+	runVmTests(t, tests)
+}
+
+func TestConstAndAssignment(t *testing.T) {
+	tests := []vmTestCase{
+		{"const a = 5; a", 5},
+		{"let a = 1; a = 2; a", 2},
+		{"let a = 1; a = a + 1", 2},
+		{"let a = 1; let b = 0; a = b = 3; a + b", 6},
+		{"let a = 1; let f = fn() { a = 10; }; f(); a", 10},
+		{"const a = 1; let f = fn() { let a = 2; a = 3; a }; f() + a", 4},
+		{"let f = fn(x) { x = x * 2; x }; f(4)", 8},
+		{"let a = 1; let a = 2; a", 2},
+	}
+
+	runVmTests(t, tests)
+}
+
+func TestBlockScoping(t *testing.T) {
+	tests := []vmTestCase{
+		{"let x = 1; if (true) { let x = 2; }; x", 1},
+		{"let x = 1; if (true) { let x = 2; x }", 2},
+		{"let x = 1; if (false) { 0 } else { let x = 3; }; x", 1},
+		{"let x = 1; if (true) { x = 2; }; x", 2},
+		{"let x = 1; if (true) { let x = 2; x = 3; }; x", 1},
+		{"const x = 1; if (true) { const x = 2; x }", 2},
+		{"let f = fn(x) { if (true) { let x = x * 10; x } }; f(2)", 20},
+		{"let f = fn() { if (true) { let y = 5; fn() { y } } }; f()()", 5},
+		{"if (true) { let y = 1; }", Null},
+		{"if (false) { 1 } else { }", Null},
+	}
+
+	runVmTests(t, tests)
+}
+
+func TestImports(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"lib/strings.mk": `
+			import "helper.mk" as h;
+			let secret = 41;
+			export let shout = fn(x) { h.wrap(x) };
+			export const answer = secret + 1;
+			export let counter = 0;
+			export let bump = fn() { counter = counter + 1; counter };
+		`,
+		"lib/helper.mk":   `export let wrap = fn(x) { "<" + x + ">" };`,
+		"lib/macros.mk":   `let unless = macro(c, a, b) { quote(if (!(unquote(c))) { unquote(a) } else { unquote(b) }) }; export let size = fn(x) { unless(x > 9, "small", "big") };`,
+		"vendor/extra.mk": `export let three = 3;`,
+		"lib/early.mk":    `export let a = 1; if (a > 0) { return 0 }; a = 2;`,
+		"a.mk":            `import "b.mk" as b;`,
+		"b.mk":            `import "a.mk" as a;`,
+	}
+	for name, src := range files {
+		path := filepath.Join(dir, name)
+		os.MkdirAll(filepath.Dir(path), 0o755)
+		if err := os.WriteFile(path, []byte(src), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []vmTestCase{
+		{`import "lib/strings.mk" as s; s.shout("hi")`, "<hi>"},
+		{`import "lib/strings.mk" as s; let secret = 1; s.answer + secret`, 43},
+		{`import "lib/strings.mk" as s; let f = fn() { s.answer }; f()`, 42},
+		{`import "lib/strings.mk" as s; import "lib/strings.mk" as t; s.bump(); t.bump(); s.counter`, 2},
+		{`import "extra.mk" as e; e.three`, 3},
+		{`import "lib/macros.mk" as m; m.size(5) + m.size(50)`, "smallbig"},
+		{`import "lib/early.mk" as e; e.a + 1`, 2},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			comp := compiler.New()
+			loader := module.NewLoader(dir, filepath.Join(dir, "vendor"))
+			loader.Expand = evaluator.ExpandProgram
+			comp.SetLoader(loader)
+
+			err := comp.Compile(parse(tt.input))
+			if err != nil {
+				t.Fatalf("compile error %s", err)
+			}
+
+			vm := New(comp.Bytecode())
+			if err := vm.Run(); err != nil {
+				t.Fatalf("vm error: %s", err)
+			}
+
+			testExpectedObject(t, tt.expected, vm.LastPoppedStackElem())
+		})
+	}
+
+	errors := []struct {
+		input    string
+		expected string
+	}{
+		{`import "lib/strings.mk" as s; s.secret`, "module lib/strings.mk has no export secret"},
+		{`import "lib/strings.mk" as s; s`, "module s can only be used to access its exports"},
+		{`import "lib/strings.mk" as s; s = 1`, "cannot assign to constant s"},
+		{`import "missing.mk" as m;`, "module not found: missing.mk"},
+		{`import "a.mk" as a;`, "import cycle: a.mk -> b.mk -> a.mk"},
+	}
+
+	for _, tt := range errors {
+		comp := compiler.New()
+		comp.SetLoader(module.NewLoader(dir, filepath.Join(dir, "vendor")))
+
+		err := comp.Compile(parse(tt.input))
+		if err == nil || err.Error() != tt.expected {
+			t.Errorf("wrong compiler error for %q. want=%q, got=%v", tt.input, tt.expected, err)
+		}
+	}
+}
+
+func TestTryCatch(t *testing.T) {
+	tests := []vmTestCase{
+		{"let r = 0; try { r = 1; } catch (e) { r = 2; }; r", 1},
+		{`let r = 0; try { throw "x"; r = 1; } catch (e) { r = e.message; }; r`, "x"},
+		{`let r = ""; try { len(1) } catch (e) { r = e.message }; r`, "argument to `len` not supported, got INTEGER"},
+		{`let r = ""; try { read_file("x") } catch (e) { r = e.kind + ": " + e.message }; r`, `PermissionError: read access to "x" denied`},
+		{`let r = ""; try { throw 5 } catch (e) { r = e.kind }; r`, "Error"},
+		{`let r = ""; try { -true } catch (e) { r = e.kind }; r`, "RuntimeError"},
+		{"let r = \"\";\ntry {\n  throw 1;\n} catch (e) { r = e.location };\nr", "line 3"},
+		{"let f = fn() {\n  1 + true\n};\nlet r = \"\";\ntry { f() } catch (e) { r = e.location };\nr", "line 2"},
+		{`let r = 0; try { throw {"message": "m", "code": 7} } catch (e) { r = e.code }; r`, 7},
+		{`let r = ""; try { try { throw "a" } catch (e) { throw e } } catch (e) { r = e.message }; r`, "a"},
+		{`let r = 0; try { r = r + 1 } finally { r = r * 10 }; r`, 10},
+		{`let r = 0; try { try { throw 1 } finally { r = 5 } } catch (e) { r = r + 1 }; r`, 6},
+		{`let r = 0; let f = fn() { try { return 1 } finally { r = 10 } }; f() + r`, 11},
+		{`let r = 0; try { try { throw 1 } catch (e) { throw 2 } finally { r = 10 } } catch (e) { r = r + 1 }; r`, 11},
+		{`let f = fn() { try { throw "x" } finally { return 5 } }; f()`, 5},
+		{`let g = fn() { throw "deep" }; let f = fn() { g() + 1 }; let r = ""; try { f() } catch (e) { r = e.message }; r`, "deep"},
+		{`let f = fn(a) { a }; let r = ""; try { f() } catch (e) { r = e.message }; r`, "wrong number of arguments. got=0, want=1"},
+		{`let r = 0; try { throw 1 } catch { r = 2 }; r`, 2},
+		{`let f = fn() { try { throw 1 } catch (e) { return e.message }; 0 }; f()`, "1"},
+		{`let f = fn() { try { throw 1 } catch (e) { 2 }; 3 }; 1 + f()`, 4},
+		{`let f = fn() { try { 1 } catch (e) { 2 } }; f()`, Null},
+		{`let r = 0; if (true) { try { r = 1 } finally { r = r + 1 } }; r`, 2},
+	}
+
+	runVmTests(t, tests)
+
+	errors := []struct {
+		input    string
+		expected string
+	}{
+		{`throw "boom"`, "boom"},
+		{`try { throw 1 } finally { 2 }`, "1"},
+		{`throw {"message": "custom"}`, "custom"},
+	}
+
+	for _, tt := range errors {
+		comp := compiler.New()
+		if err := comp.Compile(parse(tt.input)); err != nil {
+			t.Fatalf("compiler error: %s", err)
+		}
+
+		vm := New(comp.Bytecode())
+		err := vm.Run()
+		if err == nil || err.Error() != tt.expected {
+			t.Errorf("wrong VM error for %q. want=%q, got=%v", tt.input, tt.expected, err)
+		}
+	}
+}
+
+func TestCallingFunctions(t *testing.T) {
+	tests := []vmTestCase{
+		{"let fivePlusTen = fn() { 5 + 10; }; fivePlusTen();", 15},
+		{"let one = fn() { 1; }; let two = fn() { 2; }; one() + two()", 3},
+		{"let earlyExit = fn() { return 99; 100; }; earlyExit();", 99},
+		{"let noReturn = fn() { }; noReturn();", Null},
+		{"let identity = fn(a) { a; }; identity(4);", 4},
+		{"let sum = fn(a, b) { let c = a + b; c; }; sum(1, 2);", 3},
+		{
+			`
+			let globalNum = 10;
+			let sum = fn(a, b) {
+				let c = a + b;
+				c + globalNum;
+			};
+			let outer = fn() {
+				sum(1, 2) + sum(3, 4) + globalNum;
+			};
+			outer() + globalNum;
+			`,
+			50,
+		},
+	}
+
+	runVmTests(t, tests)
+}
+
+func TestDefaultAndRestParameters(t *testing.T) {
+	tests := []vmTestCase{
+		{"fn(x, y = 10) { x + y; }(1);", 11},
+		{"fn(x, y = 10) { x + y; }(1, 2);", 3},
+		{"fn(x = 1, y = x * 2) { x + y; }();", 3},
+		{"fn(x = 1, y = x * 2) { x + y; }(5);", 15},
+		{"let base = 100; fn(x = base) { x; }();", 100},
+		{"fn(first, ...rest) { rest; }(1, 2, 3);", []int{2, 3}},
+		{"fn(first, ...rest) { rest; }(1);", []int{}},
+		{"fn(...all) { all; }(1, 2, 3);", []int{1, 2, 3}},
+		{"fn(x, y = 2, ...rest) { rest; }(1);", []int{}},
+		{"fn(x, y = 2, ...rest) { [x, y, ...rest]; }(1, 5, 7, 7);", []int{1, 5, 7, 7}},
+	}
+
+	runVmTests(t, tests)
+}
+
+func TestDefaultReferencingLaterParameter(t *testing.T) {
+	tests := []struct {
+		input        string
+		expectedVM   string
+		expectedEval string
+	}{
+		{
+			"let f = fn(x = y, y = 1) { x }; f();",
+			"ERROR: Cannot find symbol y",
+			"ERROR: identifier not found: y",
+		},
+		{
+			"let f = fn(x = x) { x }; f();",
+			"ERROR: Cannot find symbol x",
+			"ERROR: identifier not found: x",
+		},
+		{"let y = 5; let f = fn(x = y, y = 1) { [x, y] }; f();", "[5, 1]", "[5, 1]"},
+		{"let f = fn(x = 1, y = x + 1) { [x, y] }; f();", "[1, 2]", "[1, 2]"},
+		{"let f = fn(x = len(more), ...more) { x }; f();", "ERROR: Cannot find symbol more",
+			"ERROR: identifier not found: more"},
+	}
+
+	for _, tt := range tests {
+		vmResult, evalResult := runEngines(tt.input)
+		if vmResult != tt.expectedVM {
+			t.Errorf("wrong vm result for %s. want=%s, got=%s",
+				tt.input, tt.expectedVM, vmResult)
+		}
+		if evalResult != tt.expectedEval {
+			t.Errorf("wrong evaluator result for %s. want=%s, got=%s",
+				tt.input, tt.expectedEval, evalResult)
+		}
+	}
+}
+
+func TestAssigningCapturedVariables(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{
+			"let mk = fn() { let n = 0; fn() { n = n + 1; n } }; let c = mk(); c();",
+			"ERROR: cannot assign to captured variable n",
+		},
+		{
+			"let f = fn(x) { fn() { if (true) { x = 2 } } }; f(1)();",
+			"ERROR: cannot assign to captured variable x",
+		},
+		{"let n = 0; let inc = fn() { n = n + 1 }; inc(); inc(); n;", "2"},
+		{"if (true) { let n = 0; let inc = fn() { n = n + 1 }; inc(); n }", "1"},
+		{"let f = fn() { let n = 0; if (true) { n = 5 }; n }; f();", "5"},
+		{"let f = fn() { let n = 0; fn() { let n = 1; n = 2; n }() }; f();", "2"},
+		{
+			"let f = fn(x) { let g = fn() { x }; x = 5; g() }; f(1);",
+			"ERROR: cannot assign to captured variable x",
+		},
+		{
+			"let f = fn(x) { let g = fn() { fn() { x } }; if (true) { x = 5 }; g()() }; f(1);",
+			"ERROR: cannot assign to captured variable x",
+		},
+		{
+			"let f = fn(x) { let g = fn() { x }; let x = 5; g() }; f(1);",
+			"ERROR: cannot redeclare captured variable x",
+		},
+		{"let f = fn(x) { x = x + 1; let g = fn() { x }; g() }; f(1);", "2"},
+		{"let f = fn() { let n = 0; let g = fn() { let n = 1; n }; n = 2; [n, g()] }; f();", "[2, 1]"},
+	}
+
+	for _, tt := range tests {
+		vmResult, evalResult := runEngines(tt.input)
+		if vmResult != tt.expected {
+			t.Errorf("wrong vm result for %s. want=%s, got=%s",
+				tt.input, tt.expected, vmResult)
+		}
+		if evalResult != tt.expected {
+			t.Errorf("wrong evaluator result for %s. want=%s, got=%s",
+				tt.input, tt.expected, evalResult)
+		}
+	}
+}
+
+func TestRedeclaringVariables(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"let x = 1; let g = fn() { x }; let x = 2; g();", "2"},
+		{"if (true) { let x = 1; let g = fn() { x }; let x = 2; g() }", "2"},
+		{
+			"let x = 1; if (true) { let g = fn() { x }; let x = 2; g() }",
+			"ERROR: cannot redeclare captured variable x",
+		},
+		{
+			"let x = 1; let f = fn() { let g = fn() { x }; let x = 2; g() }; f();",
+			"ERROR: cannot redeclare captured variable x",
+		},
+		{"let x = 1; let f = fn() { let g = fn() { x }; if (true) { let x = 2 }; g() }; f();", "1"},
+		{"let f = fn() { let x = 1; let x = x + 1; x }; f();", "2"},
+		{"const x = 1; let x = 2;", "ERROR: cannot redeclare constant x"},
+	}
+
+	for _, tt := range tests {
+		vmResult, evalResult := runEngines(tt.input)
+		if vmResult != tt.expected {
+			t.Errorf("wrong vm result for %s. want=%s, got=%s",
+				tt.input, tt.expected, vmResult)
+		}
+		if evalResult != tt.expected {
+			t.Errorf("wrong evaluator result for %s. want=%s, got=%s",
+				tt.input, tt.expected, evalResult)
+		}
+	}
+}
+
+func TestTopLevelReturn(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"return 5; 10;", "5"},
+		{"if (true) { return 1 }; 2;", "1"},
+		{"let f = fn() { return 1 }; return f() + 1; 3;", "2"},
+		{"let x = 1; try { return x } finally { x = 2 }; 3;", "1"},
+	}
+
+	for _, tt := range tests {
+		vmResult, evalResult := runEngines(tt.input)
+		if vmResult != tt.expected {
+			t.Errorf("wrong vm result for %s. want=%s, got=%s",
+				tt.input, tt.expected, vmResult)
+		}
+		if evalResult != tt.expected {
+			t.Errorf("wrong evaluator result for %s. want=%s, got=%s",
+				tt.input, tt.expected, evalResult)
+		}
+	}
+}
+
+func TestSpreadElements(t *testing.T) {
+	tests := []vmTestCase{
+		{"[...[1, 2], 3, ...[], ...[4]]", []int{1, 2, 3, 4}},
+		{"let add = fn(a, b, c) { a + b + c }; add(...[1, 2, 3]);", 6},
+		{"let add = fn(a, b, c) { a + b + c }; add(1, ...[2], 3);", 6},
+		{"let f = fn(...xs) { xs }; f(...[1, 2], ...[3]);", []int{1, 2, 3}},
+	}
+
+	runVmTests(t, tests)
+}
+
+func TestPipeAndArrowFunctions(t *testing.T) {
+	tests := []vmTestCase{
+		{"let double = x => x * 2; double(4);", 8},
+		{"let add = (a, b) => a + b; add(2, 3);", 5},
+		{"let answer = () => 42; answer();", 42},
+		{"let add = x => y => x + y; add(1)(2);", 3},
+		{"let f = (x, y = 10) => { let z = x + y; z }; f(1);", 11},
+		{"let double = x => x * 2; 5 |> double;", 10},
+		{"let sub = (a, b) => a - b; 10 |> sub(3);", 7},
+		{"let sub = (a, b) => a - b; 10 |> sub(3) |> sub(2);", 5},
+		{"3 |> (x => x * x);", 9},
+		{"let wrap = (...xs) => xs; 1 |> wrap(2, 3);", []int{1, 2, 3}},
+	}
+
+	runVmTests(t, tests)
+}
+
+func TestCallingFunctionsWithWrongArguments(t *testing.T) {
+	tests := []vmTestCase{
+		{"fn(x, y) { x + y; }(1);", "wrong number of arguments. got=1, want=2"},
+		{"fn(x) { x; }(1, 2);", "wrong number of arguments. got=2, want=1"},
+		{"fn() { 1; }(1);", "wrong number of arguments. got=1, want=0"},
+		{"fn(x, y = 1) { x; }();", "wrong number of arguments. got=0, want=1..2"},
+		{"fn(x, y = 1) { x; }(1, 2, 3);", "wrong number of arguments. got=3, want=1..2"},
+		{"fn(x, ...rest) { x; }();", "wrong number of arguments. got=0, want at least 1"},
+		{"let add = fn(a, b) { a + b }; add(...[1, 2, 3]);",
+			"wrong number of arguments. got=3, want=2"},
+		{"[...1];", "spread operand must be ARRAY, got INTEGER"},
+	}
+
+	for _, tt := range tests {
+		program := parse(tt.input)
+
+		comp := compiler.New()
+		err := comp.Compile(program)
+		if err != nil {
+			t.Fatalf("compiler error: %s", err)
+		}
+
+		vm := New(comp.Bytecode())
+		err = vm.Run()
+		if err == nil {
+			t.Fatalf("expected VM error but resulted in none.")
+		}
+
+		if err.Error() != tt.expected {
+			t.Fatalf("wrong VM error: want=%q, got=%q", tt.expected, err)
+		}
+	}
+}
+
+func TestClosures(t *testing.T) {
+	tests := []vmTestCase{
+		{
+			`
+			let newClosure = fn(a) {
+				fn() { a; };
+			};
+			let closure = newClosure(99);
+			closure();
+			`,
+			99,
+		},
+		{
+			`
+			let newAdder = fn(a, b) {
+				let c = a + b;
+				fn(d) { c + d };
+			};
+			let adder = newAdder(1, 2);
+			adder(8);
+			`,
+			11,
+		},
+		{
+			`
+			let newAdder = fn(x) {
+				fn(y = x) { x + y };
+			};
+			newAdder(2)();
+			`,
+			4,
+		},
+	}
+
+	runVmTests(t, tests)
+}
+
+func TestRecursiveFunctions(t *testing.T) {
+	tests := []vmTestCase{
+		{
+			`
+			let countDown = fn(x) {
+				if (x == 0) {
+					return 0;
+				} else {
+					countDown(x - 1);
+				}
+			};
+			countDown(1);
+			`,
+			0,
+		},
+		{
+			`
+			let wrapper = fn() {
+				let countDown = fn(x) {
+					if (x == 0) {
+						return 0;
+					} else {
+						countDown(x - 1);
+					}
+				};
+				countDown(1);
+			};
+			wrapper();
+			`,
+			0,
+		},
+	}
+
+	runVmTests(t, tests)
+}
+
+func TestMacros(t *testing.T) {
+	tests := []vmTestCase{
+		{`let unless = macro(cond, cons, alt) {
+			quote(if (!(unquote(cond))) { unquote(cons) } else { unquote(alt) })
+		};
+		let x = 10;
+		unless(x > 5, x, x * 2)`, 20},
+		{`let twice = macro(e) { quote(unquote(e) + unquote(e)) };
+		let f = fn(a) { twice(a * 2) };
+		f(3)`, 12},
+		{`let swap = macro(a, b) { quote([unquote(b), unquote(a)]) };
+		swap(1, 2)`, []int{2, 1}},
+		{`let inc = macro(a) { quote(unquote(a) + 1) };
+		[inc(1), inc(10)]`, []int{2, 11}},
+	}
+
+	for _, tt := range tests {
+		program := parse(tt.input)
+
+		macroEnv := object.NewEnvironment()
+		evaluator.DefineMacros(program, macroEnv)
+		expanded, err := evaluator.ExpandMacros(program, macroEnv)
+		if err != nil {
+			t.Fatalf("macro expansion failed: %s", err)
+		}
+
+		comp := compiler.New()
+		if err := comp.Compile(expanded); err != nil {
+			t.Fatalf("compiler error: %s", err)
+		}
+
+		vm := New(comp.Bytecode())
+		if err := vm.Run(); err != nil {
+			t.Fatalf("vm error: %s", err)
+		}
+
+		testExpectedObject(t, tt.expected, vm.LastPoppedStackElem())
+	}
+}